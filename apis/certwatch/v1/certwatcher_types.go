@@ -9,11 +9,131 @@ import (
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 type CertWatcherSecret struct {
-	// Name of the Secret watched by CertWatcher
-	Name string `json:"name"`
+	// Name of the Secret watched by CertWatcher. Either Name or Selector must
+	// be set.
+	Name string `json:"name,omitempty"`
 
 	// Namespace of the Secret watched by CertWatcher.
 	Namespace string `json:"namespace"`
+
+	// Selector watches every TLS Secret in Namespace whose labels match, or
+	// Opaque Secret when Keys are set, instead of a single Secret by name.
+	// Actions are performed for whichever Secret changed, and each one is
+	// tracked in the status.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Keys of the certificate data in the Secret, when they differ from the
+	// ones of kubernetes.io/tls Secrets. With keys set, Opaque Secrets are
+	// watched as well.
+	Keys *CertWatcherSecretKeys `json:"keys,omitempty"`
+
+	// OnDelete are the actions performed when the Secret watched by name is
+	// deleted.
+	OnDelete *CertWatcherOnDelete `json:"onDelete,omitempty"`
+
+	// OnRecreate is what happens when the Secret watched by name is created
+	// again after being deleted: Change|Rebaseline. Change performs actions as
+	// for any change, even if the contents are the same as before. Rebaseline
+	// records the checksum of the new Secret without performing actions.
+	// Defaults to Change.
+	// +kubebuilder:validation:Enum=Change;Rebaseline
+	OnRecreate string `json:"onRecreate,omitempty"`
+}
+
+// CertWatcherOnDelete are actions performed when a Secret is deleted. There
+// are no certificate files to use, so e-mails are sent without attachments.
+type CertWatcherOnDelete struct {
+	Echo  *CertWatcherActionEcho `json:"echo,omitempty"`
+	Email *CertWatchActionEmail  `json:"email,omitempty"`
+}
+
+// CertWatcherSecretKeys are the keys of the certificate data in a Secret.
+type CertWatcherSecretKeys struct {
+	// Certificate is the key of the PEM encoded certificate, optionally
+	// followed by its chain. Defaults to tls.crt.
+	Certificate string `json:"certificate,omitempty"`
+
+	// PrivateKey is the key of the PEM encoded private key. Defaults to
+	// tls.key.
+	PrivateKey string `json:"privateKey,omitempty"`
+
+	// CA is the key of the PEM encoded CA certificates, which may be missing
+	// from the Secret. Defaults to ca.crt.
+	CA string `json:"ca,omitempty"`
+}
+
+// CertWatcherConfigMap is a ConfigMap holding PEM encoded certificates,
+// without private key.
+type CertWatcherConfigMap struct {
+	// Name of the ConfigMap watched by CertWatcher.
+	Name string `json:"name"`
+
+	// Namespace of the ConfigMap watched by CertWatcher.
+	Namespace string `json:"namespace"`
+
+	// Key of the certificates in the ConfigMap, either in data or binaryData.
+	// Defaults to ca.crt.
+	Key string `json:"key,omitempty"`
+}
+
+// CertWatcherCertificate is a cert-manager Certificate.
+type CertWatcherCertificate struct {
+	// Name of the Certificate watched by CertWatcher.
+	Name string `json:"name"`
+
+	// Namespace of the Certificate watched by CertWatcher.
+	Namespace string `json:"namespace"`
+}
+
+// CertWatcherEndpoint is a remote TLS endpoint, such as a vendor API or a load
+// balancer, checked periodically for the certificates it presents.
+type CertWatcherEndpoint struct {
+	// Host name or IP address of the endpoint.
+	Host string `json:"host"`
+
+	// Port of the endpoint. Defaults to 443.
+	Port int `json:"port,omitempty"`
+
+	// ServerName sent in the TLS handshake (SNI). Defaults to Host.
+	ServerName string `json:"serverName,omitempty"`
+
+	// StartTLS is the protocol used to upgrade a plain text connection to
+	// TLS: smtp|imap|pop3|ftp. If empty, the TLS handshake is performed right
+	// after connecting.
+	// +kubebuilder:validation:Enum=smtp;imap;pop3;ftp
+	StartTLS string `json:"startTLS,omitempty"`
+
+	// Interval between checks of the endpoint, such as 15m or 1h. Defaults
+	// to 1h.
+	Interval metav1.Duration `json:"interval,omitempty"`
+
+	// Timeout for connecting and completing the handshake. Defaults to 10s.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+
+	// ExpiryThreshold is how long before the leaf certificate expires that
+	// actions are performed, once per certificate chain, such as 720h.
+	// Defaults to 336h (14 days).
+	ExpiryThreshold metav1.Duration `json:"expiryThreshold,omitempty"`
+}
+
+// CertWatcherChecksum selects what is included in the checksum used to detect
+// changes of the watched Secret or ConfigMap.
+type CertWatcherChecksum struct {
+	// Scope of the checksum: Data|Fingerprint|Keys|Trigger. Data includes all
+	// data and labels, Fingerprint only the SHA-256 fingerprints of the
+	// certificates, Keys only the values of Keys and Trigger nothing but the
+	// trigger annotation. Defaults to Data.
+	// +kubebuilder:validation:Enum=Data;Fingerprint;Keys;Trigger
+	Scope string `json:"scope,omitempty"`
+
+	// Keys of the data included in the checksum with the Keys scope.
+	Keys []string `json:"keys,omitempty"`
+
+	// TriggerAnnotation is an annotation of the Secret or ConfigMap whose
+	// value is included in the checksum with any scope, so changing it
+	// performs actions again for the same certificate. Defaults to
+	// certwatch.morimoto.net.br/trigger.
+	TriggerAnnotation string `json:"triggerAnnotation,omitempty"`
 }
 
 // CertWatcherAction represents one or more actions that will be performed when a
@@ -47,6 +167,13 @@ type CertWatchActionJob struct {
 	// certificate files into the Job's containers. Defaults to "/workspace".
 	MountPath string `json:"mountPath,omitempty"`
 
+	// Files is the list of certificate files, from the temporary workspace
+	// directory, to make available to the Job's containers in the same volume
+	// as the original Secret contents. They are stored in a Secret named after
+	// the Job, which is removed along with it. Names may be file name
+	// templates, such as `{{ .Prefix }}.p12`.
+	Files []string `json:"files,omitempty"`
+
 	// Spec is a standard Kubernetes job spec.
 	Spec v1.JobSpec `json:"spec"`
 }
@@ -80,11 +207,13 @@ type CertWatchActionScp struct {
 // using the CertWatchActionScp action. Mode defaults to 0600.
 type CertWatchScpFile struct {
 	// Name is the name of the local certificate file. Filenames are relative to the
-	// temporary workspace directory.
+	// temporary workspace directory. May be a file name template, such as
+	// `{{ .Prefix }}.crt`.
 	Name string `json:"name"`
 
 	// RemotePath is the full directory path in the remote host where the certificate
-	// will be copied to.
+	// will be copied to. May be a file name template, such as
+	// `/etc/ssl/{{ .SAN }}`.
 	RemotePath string `json:"remotePath"`
 
 	// Mode is the file mode the file on the remote host will have. A string in
@@ -136,11 +265,87 @@ type CertWatchActionEmail struct {
 	// will have: text/plain or text/html
 	BodyContentType string `json:"bodyContentType,omitempty"`
 
+	// AlternativeBodyTemplate is an alternative version of the e-mail body. Its
+	// content type is the opposite of BodyContentType, so a text/html body can be
+	// sent along with a text/plain alternative (or vice versa) and each client
+	// picks the one it renders best.
+	AlternativeBodyTemplate string `json:"alternativeBodyTemplate,omitempty"`
+
+	// InlineImagesConfigMap is the name of a ConfigMap holding images to embed in
+	// an HTML body. Each key is sent as an inline image that can be referenced
+	// by its name, as in <img src="cid:logo.png">. The reference should be in
+	// the form namespace/configmap-name.
+	InlineImagesConfigMap string `json:"inlineImagesConfigMap,omitempty"`
+
+	// ReplyTo is the header that identifies the address replies should be sent
+	// to.
+	ReplyTo string `json:"replyTo,omitempty"`
+
+	// Headers are additional custom headers to include in the e-mail, such as
+	// X-Ticket-ID.
+	Headers map[string]string `json:"headers,omitempty"`
+
 	// Attachments is the list of attachments to send with the e-mail. Paths are
 	// relative to a temporary workspace directory where different versions of the
 	// certificate files are saved before sending the email. Files will be available
-	// in popular formats, like PEM and PKCS#12, zipped and unzipped.
+	// in popular formats, like PEM and PKCS#12, zipped and unzipped. Names may
+	// be file name templates, such as `{{ .Prefix }}.zip`.
 	Attachments []string `json:"attachments,omitempty"`
+
+	// Pgp enables OpenPGP encryption of the e-mail contents for recipients that
+	// only accept PGP protected messages.
+	Pgp *CertWatchEmailPgp `json:"pgp,omitempty"`
+
+	// Digest enables digest mode, where notifications for the same recipients are
+	// buffered and sent as a single e-mail summarising every change.
+	Digest *CertWatchEmailDigest `json:"digest,omitempty"`
+}
+
+// CertWatchEmailDigest configures digest mode for e-mails. Notifications from
+// all CertWatchers sending to the same recipients (from, to, cc and bcc) through
+// the same email configuration are buffered for the duration of Window,
+// starting with the first change, and then sent as one message listing every
+// CertWatcher and Secret that changed. Addressing, custom headers and PGP
+// settings are taken from the first CertWatcher in the digest.
+type CertWatchEmailDigest struct {
+	// Window is how long notifications are buffered before the digest is sent,
+	// such as 15m or 1h. Defaults to 10m.
+	Window metav1.Duration `json:"window,omitempty"`
+
+	// Subject is the subject of the digest e-mail. Defaults to "Certificate
+	// changes".
+	Subject string `json:"subject,omitempty"`
+
+	// OmitAttachments controls whether attachments are left out of the digest,
+	// sending only the summary.
+	OmitAttachments bool `json:"omitAttachments,omitempty"`
+}
+
+// CertWatchEmailPgp configures OpenPGP encryption for e-mails. Recipient
+// public keys are read from a ConfigMap and/or a Secret, where every value is
+// expected to contain one or more armored public keys. The message is
+// encrypted to all keys found.
+//
+// Mode can be either `attachments` (default), where each attachment is
+// encrypted individually and sent as `<name>.pgp` (or `<name>.asc` when
+// armored), or `body`, where the whole MIME body, attachments included, is
+// encrypted and sent as a PGP/MIME (RFC 3156) message.
+type CertWatchEmailPgp struct {
+	// PublicKeysConfigMap is the name of a ConfigMap holding armored recipient
+	// public keys. The reference should be in the form namespace/configmap-name.
+	PublicKeysConfigMap string `json:"publicKeysConfigMap,omitempty"`
+
+	// PublicKeysSecret is the name of a Secret holding armored recipient public
+	// keys. The reference should be in the form namespace/secret-name.
+	PublicKeysSecret string `json:"publicKeysSecret,omitempty"`
+
+	// Mode is the encryption mode: attachments|body. Defaults to `attachments`.
+	Mode string `json:"mode,omitempty"`
+
+	// Armor controls whether encrypted attachments are ASCII armored (.asc)
+	// instead of binary (.pgp). Only used in `attachments` mode, as PGP/MIME
+	// bodies are always armored.
+	Armor bool `json:"armor,omitempty"`
 }
 
 // CertWatcherActionEcho Dummy action that simply generates an Event informing
@@ -149,30 +354,263 @@ type CertWatchActionEmail struct {
 type CertWatcherActionEcho struct {
 }
 
+// CertWatcherZip configures the creation of zip files.
+type CertWatcherZip struct {
+	// Encryption is the method used to encrypt files when zipFilesPassword is
+	// set: zipcrypto|aes256. The `zipcrypto` method (default) is weak, but
+	// supported by every zip tool. The `aes256` method (WinZip AES) is
+	// supported by 7-Zip, WinZip and most modern tools.
+	Encryption string `json:"encryption,omitempty"`
+
+	// Compression method: deflate|store. Defaults to `deflate`.
+	Compression string `json:"compression,omitempty"`
+
+	// CompressionLevel for the `deflate` method, from 1 (fastest) to 9 (best
+	// compression). Defaults to 6.
+	CompressionLevel int `json:"compressionLevel,omitempty"`
+}
+
+// CertWatcherPkcs12 configures the encoding of PKCS#12 files.
+type CertWatcherPkcs12 struct {
+	// Profile is the set of encryption algorithms used: legacy|modern. The
+	// `legacy` profile (default) encrypts certificates with RC2-40 and the
+	// private key with 3DES, with SHA-1 based key derivation, which is what
+	// older Windows and Java versions support. The `modern` profile encrypts
+	// both with AES-256-CBC and PBKDF2.
+	Profile string `json:"profile,omitempty"`
+
+	// FriendlyName is set on the private key and certificate entries. Most
+	// tools show it as the alias of the entry.
+	FriendlyName string `json:"friendlyName,omitempty"`
+
+	// MacAlgorithm is the integrity MAC algorithm: SHA1|SHA256|SHA512. Defaults
+	// to SHA1 in the `legacy` profile and SHA256 in the `modern` profile.
+	MacAlgorithm string `json:"macAlgorithm,omitempty"`
+}
+
+// CertWatcherKeystore configures Java keystore and truststore files.
+type CertWatcherKeystore struct {
+	// Alias of the private key entry in the keystore. Truststore entries are
+	// named `<alias>-ca-<n>`. Defaults to the filenames prefix.
+	Alias string `json:"alias,omitempty"`
+
+	// PasswordSecretKeyRef references the password of the keystore in a Secret.
+	// JKS requires at least 6 characters. Defaults to `changeit`.
+	PasswordSecretKeyRef *CertWatcherSecretKeyRef `json:"passwordSecretKeyRef,omitempty"`
+
+	// TruststorePasswordSecretKeyRef references the password of truststores in
+	// a Secret. Defaults to the keystore password.
+	TruststorePasswordSecretKeyRef *CertWatcherSecretKeyRef `json:"truststorePasswordSecretKeyRef,omitempty"`
+}
+
+// CertWatcherRandomPassword generates a new random password every time
+// the Secret changes, used for zip and PKCS#12 files instead of
+// ZipFilesPassword and Pkcs12Password. The password never travels along with
+// the files: it is delivered separately, through at least one of the channels
+// below.
+type CertWatcherRandomPassword struct {
+	// Length of the password. Defaults to 24 characters.
+	Length int `json:"length,omitempty"`
+
+	// Email sends the password in its own e-mail. Its recipients must not be
+	// recipients of the e-mail action.
+	Email *CertWatcherPasswordEmail `json:"email,omitempty"`
+
+	// Webhook posts the password to an HTTP(S) endpoint.
+	Webhook *CertWatcherPasswordWebhook `json:"webhook,omitempty"`
+
+	// Secret writes the password to a Secret in the namespace of the
+	// CertWatcher.
+	Secret *CertWatcherPasswordSecret `json:"secret,omitempty"`
+}
+
+// CertWatcherPasswordEmail sends random passwords via e-mail.
+type CertWatcherPasswordEmail struct {
+	// ConfigFile is the configuration file with information about the email
+	// server to use. Defaults to the default email configuration.
+	ConfigFile string `json:"configFile,omitempty"`
+
+	// From is the header that identifies the sender of the e-mail. If not
+	// specified here, the value must be specified in configuration file.
+	From string `json:"from,omitempty"`
+
+	// To is the header that identifies the recipients of the e-mail. A comma
+	// separated list of e-mail addresses.
+	To string `json:"to"`
+
+	// Subject of the e-mail. Defaults to "Certificate files password".
+	Subject string `json:"subject,omitempty"`
+}
+
+// CertWatcherPasswordWebhook posts random passwords to an HTTP(S) endpoint,
+// as a JSON object with certWatcher, secret, checksum and password fields.
+type CertWatcherPasswordWebhook struct {
+	// URL of the endpoint.
+	URL string `json:"url"`
+
+	// HeadersSecret is a Secret whose values are sent as HTTP headers, named
+	// after their keys, such as Authorization. The reference should be in the
+	// form namespace/secret-name.
+	HeadersSecret string `json:"headersSecret,omitempty"`
+}
+
+// CertWatcherPasswordSecret writes random passwords to a Secret.
+type CertWatcherPasswordSecret struct {
+	// Name of the Secret, in the namespace of the CertWatcher. It is created
+	// if it does not exist, owned by the CertWatcher.
+	Name string `json:"name"`
+
+	// Key of the password in the Secret. Defaults to "password".
+	Key string `json:"key,omitempty"`
+}
+
+// CertWatcherValidation configures the checks made on the Secret contents
+// before any action is performed, on top of the ones always made: the
+// private key must match the certificate and the certificate must be
+// currently valid.
+type CertWatcherValidation struct {
+	// VerifyChain verifies the certificate chain up to a root, found in
+	// tls.crt or ca.crt, or in the CA bundle when one is provided.
+	VerifyChain bool `json:"verifyChain,omitempty"`
+
+	// CABundleSecretKeyRef references, in a Secret, the PEM encoded root
+	// certificates to verify the chain against. Implies VerifyChain.
+	CABundleSecretKeyRef *CertWatcherSecretKeyRef `json:"caBundleSecretKeyRef,omitempty"`
+
+	// MinRSAKeySize is the minimum size, in bits, of RSA keys, such as 2048.
+	MinRSAKeySize int `json:"minRSAKeySize,omitempty"`
+
+	// MinECKeySize is the minimum size, in bits, of EC keys, such as 256.
+	MinECKeySize int `json:"minECKeySize,omitempty"`
+
+	// SignatureAlgorithms is the list of signature algorithms allowed in the
+	// certificate and its intermediates, such as SHA256-RSA, SHA384-RSA,
+	// ECDSA-SHA256 or ECDSA-SHA384. If empty, any algorithm is allowed.
+	SignatureAlgorithms []string `json:"signatureAlgorithms,omitempty"`
+}
+
+// CertWatcherSecretKeyRef references a single value in a Secret.
+type CertWatcherSecretKeyRef struct {
+	// Name of the Secret. The reference should be in the form
+	// namespace/secret-name.
+	Name string `json:"name"`
+
+	// Key of the value in the Secret.
+	Key string `json:"key"`
+}
+
 // CertWatcherSpec defines the desired state of CertWatcher
 type CertWatcherSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// Secret watched by CertWatcher
-	Secret CertWatcherSecret `json:"secret"`
+	// Secret watched by CertWatcher. Exactly one of Secret, ConfigMap,
+	// Certificate or Endpoint must be set.
+	Secret CertWatcherSecret `json:"secret,omitempty"`
 
+	// ConfigMap watched by CertWatcher instead of a Secret, holding
+	// certificates without private key, such as CA bundles.
+	ConfigMap *CertWatcherConfigMap `json:"configMap,omitempty"`
+
+	// Certificate is a cert-manager Certificate watched by CertWatcher
+	// instead of a Secret. Actions are performed for its Secret whenever a
+	// new revision is issued and Ready.
+	Certificate *CertWatcherCertificate `json:"certificate,omitempty"`
+
+	// Endpoint is a remote TLS endpoint watched by CertWatcher instead of a
+	// Secret. Actions are performed for the certificates it presents when
+	// they change or the leaf certificate is about to expire.
+	Endpoint *CertWatcherEndpoint `json:"endpoint,omitempty"`
+
+	CertWatcherSettings `json:",inline"`
+}
+
+// CertWatcherSettings are the certificate files and actions of a CertWatcher,
+// also used by ClusterCertWatchers for the CertWatchers they create.
+type CertWatcherSettings struct {
 	// ZipFilesPassword is the password that should be used to zip certificate files.
 	// Zipped versions of each certificates are kept along with the raw files. If
 	// this values is empty, zip files will no tbe protected with any password.
+	//
+	// Deprecated: the password is visible to anyone allowed to read
+	// CertWatchers. Use ZipFilesPasswordSecretKeyRef instead.
 	ZipFilesPassword string `json:"zipFilesPassword,omitempty"`
 
+	// ZipFilesPasswordSecretKeyRef references, in a Secret, the password that
+	// should be used to zip certificate files. Takes precedence over
+	// ZipFilesPassword.
+	ZipFilesPasswordSecretKeyRef *CertWatcherSecretKeyRef `json:"zipFilesPasswordSecretKeyRef,omitempty"`
+
+	// Zip configures the encryption and compression of zip files. If empty,
+	// password protected zip files use ZipCrypto encryption and deflate
+	// compression.
+	Zip *CertWatcherZip `json:"zip,omitempty"`
+
 	// Pkcs12Password is the password that should be used in the PKCS#12 envelope. If
 	// empty, p12 certificate files will not be protected by any password.
+	//
+	// Deprecated: the password is visible to anyone allowed to read
+	// CertWatchers. Use Pkcs12PasswordSecretKeyRef instead.
 	Pkcs12Password string `json:"pkcs12Password,omitempty"`
 
+	// Pkcs12PasswordSecretKeyRef references, in a Secret, the password that
+	// should be used in the PKCS#12 envelope. Takes precedence over
+	// Pkcs12Password.
+	Pkcs12PasswordSecretKeyRef *CertWatcherSecretKeyRef `json:"pkcs12PasswordSecretKeyRef,omitempty"`
+
+	// Pkcs12 configures how PKCS#12 (p12) certificate files are encoded. If
+	// empty, the legacy profile is used.
+	Pkcs12 *CertWatcherPkcs12 `json:"pkcs12,omitempty"`
+
+	// Keystore configures Java keystore (jks) and truststore certificate files.
+	Keystore *CertWatcherKeystore `json:"keystore,omitempty"`
+
+	// KeyPasswordSecretKeyRef references, in a Secret, the password used to
+	// encrypt the private key in the encrypted.key format, which requires it.
+	KeyPasswordSecretKeyRef *CertWatcherSecretKeyRef `json:"keyPasswordSecretKeyRef,omitempty"`
+
+	// Checksum selects which changes of the watched Secret or ConfigMap
+	// trigger actions. If empty, any change of its data or labels does.
+	Checksum *CertWatcherChecksum `json:"checksum,omitempty"`
+
+	// Validation configures additional checks of the Secret contents before
+	// actions are performed. Actions are not performed if any check fails.
+	Validation *CertWatcherValidation `json:"validation,omitempty"`
+
+	// RandomPassword generates a new password for zip and PKCS#12 files every
+	// time the Secret changes, delivered separately from the files.
+	RandomPassword *CertWatcherRandomPassword `json:"randomPassword,omitempty"`
+
 	// FilenamesPrefix is the prefix that should be used in the exported certificate
 	// filenames. If empty, defaults to "tls", so files will be created in the
 	// temporary workspace directory as tls.key, tls.crt, tls.p12, etc...
+	// May be a file name template with certificate and CertWatcher values, such
+	// as `{{ .CommonName }}-{{ .NotAfter.Format "2006-01-02" }}`.
 	FilenamesPrefix string `json:"filenamesPrefix,omitempty"`
 
+	// Formats is the list of certificate file formats to create in the
+	// temporary workspace directory: pem|der|p12|crt.p12|key.zip|crt.zip|zip|
+	// p12.zip|crt.p12.zip|all.zip|jks|truststore.jks|truststore.p12|leaf.crt|
+	// chain.crt|ca.crt|fullchain.crt|combined.pem|pkcs1.key|pkcs8.key|
+	// encrypted.key. If empty,
+	// the pem, p12, crt.p12 and all zip formats are created, unless the
+	// CertWatcher has no actions that use files (email, scp and job with
+	// files), in which case no files are created. Zip formats also create the
+	// files they contain. Watching a ConfigMap or an Endpoint, only pem
+	// (tls.crt alone), crt.p12, crt.zip, crt.p12.zip, truststore.jks and
+	// truststore.p12 are available, and pem, crt.p12 and their zip formats
+	// are the default.
+	Formats []string `json:"formats,omitempty"`
+
 	// Actions that should be performed when the watched Secret changes.
 	Actions CertWatcherAction `json:"actions,omitempty"`
+
+	// OnSpecChange is what happens when the spec of a Ready CertWatcher
+	// changes: RunAll|RunNew|Wait. RunAll performs all actions for the
+	// current Secret contents, RunNew only the actions added or changed, and
+	// Wait none until the next Secret change. Defaults to Wait.
+	// +kubebuilder:validation:Enum=RunAll;RunNew;Wait
+	OnSpecChange string `json:"onSpecChange,omitempty"`
 }
 
 // CertWatcherStatus defines the observed state of CertWatcher
@@ -185,6 +623,174 @@ type CertWatcherStatus struct {
 	LastChecksum string      `json:"lastChecksum,omitempty"`
 	ActionStatus string      `json:"actionStatus,omitempty"`
 	Message      string      `json:"message,omitempty"`
+
+	// EmailDigest tracks the notification of the last change when the e-mail
+	// action is in digest mode.
+	EmailDigest *CertWatcherEmailDigestStatus `json:"emailDigest,omitempty"`
+
+	// Conditions of the CertWatcher, such as CertificateValid.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Secrets tracks each Secret matched by the selector, when the CertWatcher
+	// uses one.
+	Secrets []CertWatcherSecretStatus `json:"secrets,omitempty"`
+
+	// Certificate is the last revision of the cert-manager Certificate that
+	// was seen Ready, when the CertWatcher watches one.
+	Certificate *CertWatcherCertificateStatus `json:"certificate,omitempty"`
+
+	// Endpoint is the result of the last check of the remote endpoint, when
+	// the CertWatcher watches one.
+	Endpoint *CertWatcherEndpointStatus `json:"endpoint,omitempty"`
+
+	// Run is the last run of actions requested with the run annotation.
+	Run *CertWatcherRunStatus `json:"run,omitempty"`
+
+	// ObservedGeneration is the generation of the spec last seen by the
+	// controller.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ActionChecksums are checksums of the configuration of each action at
+	// ObservedGeneration, to tell which ones changed along with the spec.
+	ActionChecksums map[string]string `json:"actionChecksums,omitempty"`
+
+	// ChecksumSettings is a checksum of the settings deciding what the
+	// checksum of the source includes at ObservedGeneration, to tell when
+	// LastChecksum has to be calculated again.
+	ChecksumSettings string `json:"checksumSettings,omitempty"`
+
+	// PendingActions limits the actions performed while Pending to the ones
+	// listed, when only some of them are to be performed.
+	PendingActions []string `json:"pendingActions,omitempty"`
+
+	// SecretDeleted is set while the Secret watched by name is deleted.
+	SecretDeleted *CertWatcherSecretDeletedStatus `json:"secretDeleted,omitempty"`
+}
+
+// CertWatcherSecretDeletedStatus is the deletion of the Secret watched by a
+// CertWatcher.
+type CertWatcherSecretDeletedStatus struct {
+	// DeletedAt is when the deletion was noticed.
+	DeletedAt metav1.Time `json:"deletedAt,omitempty"`
+
+	// ActionStatus is Pending while onDelete actions are still to be
+	// performed, and Ready afterwards. Empty without onDelete actions.
+	ActionStatus string `json:"actionStatus,omitempty"`
+}
+
+// CertWatcherRunAnnotation requests the actions of a CertWatcher to be
+// performed again, for its current Secret contents, whenever its value
+// changes. The value is a nonce, such as a timestamp, different from the one
+// of the last run. The annotation is removed once actions are performed.
+const CertWatcherRunAnnotation = "certwatch.morimoto.net.br/run"
+
+// CertWatcherRunStatus is a run of actions requested with the run annotation.
+type CertWatcherRunStatus struct {
+	// Nonce is the value of the run annotation.
+	Nonce string `json:"nonce"`
+
+	// RequestedBy is the field manager that set the run annotation, such as
+	// kubectl-annotate.
+	RequestedBy string `json:"requestedBy,omitempty"`
+
+	// RequestedAt is when the run was noticed.
+	RequestedAt metav1.Time `json:"requestedAt,omitempty"`
+
+	// CompletedAt is when actions were performed successfully.
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+// CertWatcherEndpointStatus is the result of the last check of a remote TLS
+// endpoint.
+type CertWatcherEndpointStatus struct {
+	// LastCheck is when the endpoint was last checked.
+	LastCheck metav1.Time `json:"lastCheck,omitempty"`
+
+	// NotAfter is when the leaf certificate presented by the endpoint
+	// expires.
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+
+	// ExpiryNotified is set once actions were performed for the approaching
+	// expiry of the current certificate chain.
+	ExpiryNotified bool `json:"expiryNotified,omitempty"`
+}
+
+// CertWatcherCertificateStatus is a revision of a cert-manager Certificate.
+type CertWatcherCertificateStatus struct {
+	// SecretName is the Secret of the Certificate.
+	SecretName string `json:"secretName,omitempty"`
+
+	// Revision of the Certificate.
+	Revision int64 `json:"revision,omitempty"`
+}
+
+// CertWatcherSecretStatus is the state of one of the Secrets matched by the
+// selector of a CertWatcher.
+type CertWatcherSecretStatus struct {
+	// Name of the Secret.
+	Name string `json:"name"`
+
+	// Checksum of the Secret data.
+	Checksum string `json:"checksum,omitempty"`
+
+	// ActionStatus is Pending while actions for the last change of the Secret
+	// are still to be performed, and Ready afterwards.
+	ActionStatus string `json:"actionStatus,omitempty"`
+
+	// Message describes the result of the last actions for the Secret.
+	Message string `json:"message,omitempty"`
+
+	// LastUpdate is when the entry last changed.
+	LastUpdate metav1.Time `json:"lastUpdate,omitempty"`
+}
+
+// CertWatcherConditionCertificateValid is the condition reporting whether the
+// Secret contents passed validation. Actions are only performed when True.
+const CertWatcherConditionCertificateValid = "CertificateValid"
+
+// CertWatcherConditionCertificateReady mirrors the Ready condition of the
+// cert-manager Certificate watched by a CertWatcher, surfacing issuance
+// failures.
+const CertWatcherConditionCertificateReady = "CertificateReady"
+
+// CertWatcherConditionSecretMissing reports whether the Secret watched by name
+// by a CertWatcher was deleted.
+const CertWatcherConditionSecretMissing = "SecretMissing"
+
+// CertWatcherConditionEndpointReachable reports whether the last TLS
+// handshake with the remote endpoint watched by a CertWatcher succeeded.
+const CertWatcherConditionEndpointReachable = "EndpointReachable"
+
+// CertWatcherEmailDigestStatus is the state of a change notification buffered
+// for a digest e-mail.
+type CertWatcherEmailDigestStatus struct {
+	// State is one of Queued, Sent, Retrying or Failed. Failed notifications
+	// are no longer retried.
+	State string `json:"state,omitempty"`
+
+	// Checksum of the Secret change the notification refers to.
+	Checksum string `json:"checksum,omitempty"`
+
+	// Source the notification refers to: namespace/name of the Secret,
+	// ConfigMap or Certificate, or host:port of the endpoint.
+	Source string `json:"source,omitempty"`
+
+	// QueuedAt is when the notification was added to the digest.
+	QueuedAt metav1.Time `json:"queuedAt,omitempty"`
+
+	// SentAt is when the digest including the notification was sent.
+	SentAt *metav1.Time `json:"sentAt,omitempty"`
+
+	// Message holds the delivery error when the digest could not be sent.
+	Message string `json:"message,omitempty"`
+
+	// Attempts is the number of digests that failed to include the
+	// notification. Retrying notifications are queued again after a delay
+	// that doubles with every attempt, up to 10 attempts.
+	Attempts int `json:"attempts,omitempty"`
+
+	// FailedAt is when the last digest including the notification failed.
+	FailedAt *metav1.Time `json:"failedAt,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,103 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterCertWatcherLabel is set on the CertWatchers created by a
+// ClusterCertWatcher, with its name as value.
+const ClusterCertWatcherLabel = "certwatch.morimoto.net.br/cluster-certwatcher"
+
+// ClusterCertWatcherSpec defines the desired state of ClusterCertWatcher
+type ClusterCertWatcherSpec struct {
+	// NamespaceSelector selects the namespaces watched by the
+	// ClusterCertWatcher. If empty, all namespaces are watched.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// SecretSelector selects the TLS Secrets watched in each namespace. If
+	// empty, all TLS Secrets are watched.
+	SecretSelector *metav1.LabelSelector `json:"secretSelector,omitempty"`
+
+	// SecretKeys are the keys of the certificate data in the Secrets, as in
+	// the keys of a CertWatcher secret.
+	SecretKeys *CertWatcherSecretKeys `json:"secretKeys,omitempty"`
+
+	// Certificate files and actions of the CertWatchers created in each
+	// namespace, the same as in a CertWatcher.
+	CertWatcherSettings `json:",inline"`
+}
+
+// ClusterCertWatcherStatus defines the observed state of ClusterCertWatcher
+type ClusterCertWatcherStatus struct {
+	// Status is Ready when CertWatchers are in place for all selected
+	// namespaces.
+	Status     string      `json:"status,omitempty"`
+	LastUpdate metav1.Time `json:"lastUpdate,omitempty"`
+	Message    string      `json:"message,omitempty"`
+
+	// Namespaces is the number of namespaces selected.
+	Namespaces int `json:"namespaces,omitempty"`
+
+	// Secrets is the number of Secrets watched across all namespaces.
+	Secrets int `json:"secrets,omitempty"`
+
+	// PendingSecrets is the number of Secrets with actions still to be
+	// performed, including those whose actions failed.
+	PendingSecrets int `json:"pendingSecrets,omitempty"`
+
+	// CertWatchers lists only the CertWatchers that need attention, either
+	// not Ready or with pending actions. Details of each Secret are kept in
+	// the status of the CertWatcher of its namespace.
+	CertWatchers []ClusterCertWatcherCertWatcherStatus `json:"certWatchers,omitempty"`
+}
+
+// ClusterCertWatcherCertWatcherStatus summarizes the state of a CertWatcher
+// created by a ClusterCertWatcher.
+type ClusterCertWatcherCertWatcherStatus struct {
+	// Namespace of the CertWatcher.
+	Namespace string `json:"namespace"`
+
+	// Status and ActionStatus of the CertWatcher.
+	Status       string `json:"status,omitempty"`
+	ActionStatus string `json:"actionStatus,omitempty"`
+
+	// PendingSecrets is the number of Secrets in the namespace with actions
+	// still to be performed.
+	PendingSecrets int `json:"pendingSecrets,omitempty"`
+
+	// Message of the CertWatcher.
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+
+// ClusterCertWatcher is the Schema for the clustercertwatchers API. It creates
+// a CertWatcher in each selected namespace, watching the selected Secrets.
+// +kubebuilder:printcolumn:name="NAMESPACES",type=integer,JSONPath=`.status.namespaces`
+// +kubebuilder:printcolumn:name="SECRETS",type=integer,JSONPath=`.status.secrets`
+// +kubebuilder:printcolumn:name="PENDING",type=integer,JSONPath=`.status.pendingSecrets`
+// +kubebuilder:printcolumn:name="STATUS",type=string,JSONPath=`.status.status`
+// +kubebuilder:printcolumn:name="LAST_UPDATE",type=string,JSONPath=`.status.lastUpdate`
+// +kubebuilder:printcolumn:name="MESSAGE",type=string,JSONPath=`.status.message`
+type ClusterCertWatcher struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterCertWatcherSpec   `json:"spec,omitempty"`
+	Status ClusterCertWatcherStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterCertWatcherList contains a list of ClusterCertWatcher
+type ClusterCertWatcherList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterCertWatcher `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterCertWatcher{}, &ClusterCertWatcherList{})
+}
@@ -6,17 +6,35 @@
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertWatchActionEmail) DeepCopyInto(out *CertWatchActionEmail) {
 	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Attachments != nil {
 		in, out := &in.Attachments, &out.Attachments
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Pgp != nil {
+		in, out := &in.Pgp, &out.Pgp
+		*out = new(CertWatchEmailPgp)
+		**out = **in
+	}
+	if in.Digest != nil {
+		in, out := &in.Digest, &out.Digest
+		*out = new(CertWatchEmailDigest)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatchActionEmail.
@@ -32,6 +50,11 @@ func (in *CertWatchActionEmail) DeepCopy() *CertWatchActionEmail {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertWatchActionJob) DeepCopyInto(out *CertWatchActionJob) {
 	*out = *in
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.Spec.DeepCopyInto(&out.Spec)
 }
 
@@ -65,6 +88,37 @@ func (in *CertWatchActionScp) DeepCopy() *CertWatchActionScp {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatchEmailDigest) DeepCopyInto(out *CertWatchEmailDigest) {
+	*out = *in
+	out.Window = in.Window
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatchEmailDigest.
+func (in *CertWatchEmailDigest) DeepCopy() *CertWatchEmailDigest {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatchEmailDigest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatchEmailPgp) DeepCopyInto(out *CertWatchEmailPgp) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatchEmailPgp.
+func (in *CertWatchEmailPgp) DeepCopy() *CertWatchEmailPgp {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatchEmailPgp)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertWatchScpFile) DeepCopyInto(out *CertWatchScpFile) {
 	*out = *in
@@ -157,6 +211,158 @@ func (in *CertWatcherActionEcho) DeepCopy() *CertWatcherActionEcho {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherCertificate) DeepCopyInto(out *CertWatcherCertificate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherCertificate.
+func (in *CertWatcherCertificate) DeepCopy() *CertWatcherCertificate {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherCertificate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherCertificateStatus) DeepCopyInto(out *CertWatcherCertificateStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherCertificateStatus.
+func (in *CertWatcherCertificateStatus) DeepCopy() *CertWatcherCertificateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherCertificateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherChecksum) DeepCopyInto(out *CertWatcherChecksum) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherChecksum.
+func (in *CertWatcherChecksum) DeepCopy() *CertWatcherChecksum {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherChecksum)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherConfigMap) DeepCopyInto(out *CertWatcherConfigMap) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherConfigMap.
+func (in *CertWatcherConfigMap) DeepCopy() *CertWatcherConfigMap {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherConfigMap)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherEmailDigestStatus) DeepCopyInto(out *CertWatcherEmailDigestStatus) {
+	*out = *in
+	in.QueuedAt.DeepCopyInto(&out.QueuedAt)
+	if in.SentAt != nil {
+		in, out := &in.SentAt, &out.SentAt
+		*out = (*in).DeepCopy()
+	}
+	if in.FailedAt != nil {
+		in, out := &in.FailedAt, &out.FailedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherEmailDigestStatus.
+func (in *CertWatcherEmailDigestStatus) DeepCopy() *CertWatcherEmailDigestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherEmailDigestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherEndpoint) DeepCopyInto(out *CertWatcherEndpoint) {
+	*out = *in
+	out.Interval = in.Interval
+	out.Timeout = in.Timeout
+	out.ExpiryThreshold = in.ExpiryThreshold
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherEndpoint.
+func (in *CertWatcherEndpoint) DeepCopy() *CertWatcherEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherEndpointStatus) DeepCopyInto(out *CertWatcherEndpointStatus) {
+	*out = *in
+	in.LastCheck.DeepCopyInto(&out.LastCheck)
+	if in.NotAfter != nil {
+		in, out := &in.NotAfter, &out.NotAfter
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherEndpointStatus.
+func (in *CertWatcherEndpointStatus) DeepCopy() *CertWatcherEndpointStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherEndpointStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherKeystore) DeepCopyInto(out *CertWatcherKeystore) {
+	*out = *in
+	if in.PasswordSecretKeyRef != nil {
+		in, out := &in.PasswordSecretKeyRef, &out.PasswordSecretKeyRef
+		*out = new(CertWatcherSecretKeyRef)
+		**out = **in
+	}
+	if in.TruststorePasswordSecretKeyRef != nil {
+		in, out := &in.TruststorePasswordSecretKeyRef, &out.TruststorePasswordSecretKeyRef
+		*out = new(CertWatcherSecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherKeystore.
+func (in *CertWatcherKeystore) DeepCopy() *CertWatcherKeystore {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherKeystore)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertWatcherList) DeepCopyInto(out *CertWatcherList) {
 	*out = *in
@@ -189,9 +395,159 @@ func (in *CertWatcherList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherOnDelete) DeepCopyInto(out *CertWatcherOnDelete) {
+	*out = *in
+	if in.Echo != nil {
+		in, out := &in.Echo, &out.Echo
+		*out = new(CertWatcherActionEcho)
+		**out = **in
+	}
+	if in.Email != nil {
+		in, out := &in.Email, &out.Email
+		*out = new(CertWatchActionEmail)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherOnDelete.
+func (in *CertWatcherOnDelete) DeepCopy() *CertWatcherOnDelete {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherOnDelete)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherPasswordEmail) DeepCopyInto(out *CertWatcherPasswordEmail) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherPasswordEmail.
+func (in *CertWatcherPasswordEmail) DeepCopy() *CertWatcherPasswordEmail {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherPasswordEmail)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherPasswordSecret) DeepCopyInto(out *CertWatcherPasswordSecret) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherPasswordSecret.
+func (in *CertWatcherPasswordSecret) DeepCopy() *CertWatcherPasswordSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherPasswordSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherPasswordWebhook) DeepCopyInto(out *CertWatcherPasswordWebhook) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherPasswordWebhook.
+func (in *CertWatcherPasswordWebhook) DeepCopy() *CertWatcherPasswordWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherPasswordWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherPkcs12) DeepCopyInto(out *CertWatcherPkcs12) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherPkcs12.
+func (in *CertWatcherPkcs12) DeepCopy() *CertWatcherPkcs12 {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherPkcs12)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherRandomPassword) DeepCopyInto(out *CertWatcherRandomPassword) {
+	*out = *in
+	if in.Email != nil {
+		in, out := &in.Email, &out.Email
+		*out = new(CertWatcherPasswordEmail)
+		**out = **in
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(CertWatcherPasswordWebhook)
+		**out = **in
+	}
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(CertWatcherPasswordSecret)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherRandomPassword.
+func (in *CertWatcherRandomPassword) DeepCopy() *CertWatcherRandomPassword {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherRandomPassword)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherRunStatus) DeepCopyInto(out *CertWatcherRunStatus) {
+	*out = *in
+	in.RequestedAt.DeepCopyInto(&out.RequestedAt)
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherRunStatus.
+func (in *CertWatcherRunStatus) DeepCopy() *CertWatcherRunStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherRunStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertWatcherSecret) DeepCopyInto(out *CertWatcherSecret) {
 	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = new(CertWatcherSecretKeys)
+		**out = **in
+	}
+	if in.OnDelete != nil {
+		in, out := &in.OnDelete, &out.OnDelete
+		*out = new(CertWatcherOnDelete)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherSecret.
@@ -205,12 +561,155 @@ func (in *CertWatcherSecret) DeepCopy() *CertWatcherSecret {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CertWatcherSpec) DeepCopyInto(out *CertWatcherSpec) {
+func (in *CertWatcherSecretDeletedStatus) DeepCopyInto(out *CertWatcherSecretDeletedStatus) {
+	*out = *in
+	in.DeletedAt.DeepCopyInto(&out.DeletedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherSecretDeletedStatus.
+func (in *CertWatcherSecretDeletedStatus) DeepCopy() *CertWatcherSecretDeletedStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherSecretDeletedStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherSecretKeyRef) DeepCopyInto(out *CertWatcherSecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherSecretKeyRef.
+func (in *CertWatcherSecretKeyRef) DeepCopy() *CertWatcherSecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherSecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherSecretKeys) DeepCopyInto(out *CertWatcherSecretKeys) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherSecretKeys.
+func (in *CertWatcherSecretKeys) DeepCopy() *CertWatcherSecretKeys {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherSecretKeys)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherSecretStatus) DeepCopyInto(out *CertWatcherSecretStatus) {
 	*out = *in
-	out.Secret = in.Secret
+	in.LastUpdate.DeepCopyInto(&out.LastUpdate)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherSecretStatus.
+func (in *CertWatcherSecretStatus) DeepCopy() *CertWatcherSecretStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherSecretStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherSettings) DeepCopyInto(out *CertWatcherSettings) {
+	*out = *in
+	if in.ZipFilesPasswordSecretKeyRef != nil {
+		in, out := &in.ZipFilesPasswordSecretKeyRef, &out.ZipFilesPasswordSecretKeyRef
+		*out = new(CertWatcherSecretKeyRef)
+		**out = **in
+	}
+	if in.Zip != nil {
+		in, out := &in.Zip, &out.Zip
+		*out = new(CertWatcherZip)
+		**out = **in
+	}
+	if in.Pkcs12PasswordSecretKeyRef != nil {
+		in, out := &in.Pkcs12PasswordSecretKeyRef, &out.Pkcs12PasswordSecretKeyRef
+		*out = new(CertWatcherSecretKeyRef)
+		**out = **in
+	}
+	if in.Pkcs12 != nil {
+		in, out := &in.Pkcs12, &out.Pkcs12
+		*out = new(CertWatcherPkcs12)
+		**out = **in
+	}
+	if in.Keystore != nil {
+		in, out := &in.Keystore, &out.Keystore
+		*out = new(CertWatcherKeystore)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KeyPasswordSecretKeyRef != nil {
+		in, out := &in.KeyPasswordSecretKeyRef, &out.KeyPasswordSecretKeyRef
+		*out = new(CertWatcherSecretKeyRef)
+		**out = **in
+	}
+	if in.Checksum != nil {
+		in, out := &in.Checksum, &out.Checksum
+		*out = new(CertWatcherChecksum)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Validation != nil {
+		in, out := &in.Validation, &out.Validation
+		*out = new(CertWatcherValidation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RandomPassword != nil {
+		in, out := &in.RandomPassword, &out.RandomPassword
+		*out = new(CertWatcherRandomPassword)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Formats != nil {
+		in, out := &in.Formats, &out.Formats
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.Actions.DeepCopyInto(&out.Actions)
 }
 
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherSettings.
+func (in *CertWatcherSettings) DeepCopy() *CertWatcherSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherSpec) DeepCopyInto(out *CertWatcherSpec) {
+	*out = *in
+	in.Secret.DeepCopyInto(&out.Secret)
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(CertWatcherConfigMap)
+		**out = **in
+	}
+	if in.Certificate != nil {
+		in, out := &in.Certificate, &out.Certificate
+		*out = new(CertWatcherCertificate)
+		**out = **in
+	}
+	if in.Endpoint != nil {
+		in, out := &in.Endpoint, &out.Endpoint
+		*out = new(CertWatcherEndpoint)
+		**out = **in
+	}
+	in.CertWatcherSettings.DeepCopyInto(&out.CertWatcherSettings)
+}
+
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherSpec.
 func (in *CertWatcherSpec) DeepCopy() *CertWatcherSpec {
 	if in == nil {
@@ -225,6 +724,57 @@ func (in *CertWatcherSpec) DeepCopy() *CertWatcherSpec {
 func (in *CertWatcherStatus) DeepCopyInto(out *CertWatcherStatus) {
 	*out = *in
 	in.LastUpdate.DeepCopyInto(&out.LastUpdate)
+	if in.EmailDigest != nil {
+		in, out := &in.EmailDigest, &out.EmailDigest
+		*out = new(CertWatcherEmailDigestStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = make([]CertWatcherSecretStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Certificate != nil {
+		in, out := &in.Certificate, &out.Certificate
+		*out = new(CertWatcherCertificateStatus)
+		**out = **in
+	}
+	if in.Endpoint != nil {
+		in, out := &in.Endpoint, &out.Endpoint
+		*out = new(CertWatcherEndpointStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Run != nil {
+		in, out := &in.Run, &out.Run
+		*out = new(CertWatcherRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ActionChecksums != nil {
+		in, out := &in.ActionChecksums, &out.ActionChecksums
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PendingActions != nil {
+		in, out := &in.PendingActions, &out.PendingActions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecretDeleted != nil {
+		in, out := &in.SecretDeleted, &out.SecretDeleted
+		*out = new(CertWatcherSecretDeletedStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherStatus.
@@ -236,3 +786,169 @@ func (in *CertWatcherStatus) DeepCopy() *CertWatcherStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherValidation) DeepCopyInto(out *CertWatcherValidation) {
+	*out = *in
+	if in.CABundleSecretKeyRef != nil {
+		in, out := &in.CABundleSecretKeyRef, &out.CABundleSecretKeyRef
+		*out = new(CertWatcherSecretKeyRef)
+		**out = **in
+	}
+	if in.SignatureAlgorithms != nil {
+		in, out := &in.SignatureAlgorithms, &out.SignatureAlgorithms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherValidation.
+func (in *CertWatcherValidation) DeepCopy() *CertWatcherValidation {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherValidation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertWatcherZip) DeepCopyInto(out *CertWatcherZip) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertWatcherZip.
+func (in *CertWatcherZip) DeepCopy() *CertWatcherZip {
+	if in == nil {
+		return nil
+	}
+	out := new(CertWatcherZip)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterCertWatcher) DeepCopyInto(out *ClusterCertWatcher) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCertWatcher.
+func (in *ClusterCertWatcher) DeepCopy() *ClusterCertWatcher {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterCertWatcher)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterCertWatcher) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterCertWatcherCertWatcherStatus) DeepCopyInto(out *ClusterCertWatcherCertWatcherStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCertWatcherCertWatcherStatus.
+func (in *ClusterCertWatcherCertWatcherStatus) DeepCopy() *ClusterCertWatcherCertWatcherStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterCertWatcherCertWatcherStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterCertWatcherList) DeepCopyInto(out *ClusterCertWatcherList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterCertWatcher, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCertWatcherList.
+func (in *ClusterCertWatcherList) DeepCopy() *ClusterCertWatcherList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterCertWatcherList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterCertWatcherList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterCertWatcherSpec) DeepCopyInto(out *ClusterCertWatcherSpec) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretSelector != nil {
+		in, out := &in.SecretSelector, &out.SecretSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeys != nil {
+		in, out := &in.SecretKeys, &out.SecretKeys
+		*out = new(CertWatcherSecretKeys)
+		**out = **in
+	}
+	in.CertWatcherSettings.DeepCopyInto(&out.CertWatcherSettings)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCertWatcherSpec.
+func (in *ClusterCertWatcherSpec) DeepCopy() *ClusterCertWatcherSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterCertWatcherSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterCertWatcherStatus) DeepCopyInto(out *ClusterCertWatcherStatus) {
+	*out = *in
+	in.LastUpdate.DeepCopyInto(&out.LastUpdate)
+	if in.CertWatchers != nil {
+		in, out := &in.CertWatchers, &out.CertWatchers
+		*out = make([]ClusterCertWatcherCertWatcherStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCertWatcherStatus.
+func (in *ClusterCertWatcherStatus) DeepCopy() *ClusterCertWatcherStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterCertWatcherStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,157 @@
+package certmanager
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
+)
+
+var retryFastDelay = time.Second * time.Duration(5)
+var retrySlowDelay = time.Second * time.Duration(30)
+var retryMaxFastAttempts = 5
+var log = ctrl.Log.WithName("CertificateController")
+
+// CertificateReconciler reconciles cert-manager Certificates, handled as
+// unstructured objects, for the CertWatchers watching them. A CertWatcher gets
+// Pending actions when a new revision of its Certificate is issued and Ready.
+type CertificateReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch
+
+func (r *CertificateReconciler) updateCertWatcher(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (ctrl.Result, error) {
+	certwatcher.Status.LastUpdate = apimachineryv1.Now()
+	if err := r.Status().Update(ctx, certwatcher); err != nil {
+		log.Error(err, certwatcher.Namespace+"/"+certwatcher.Name+" Unable to update CertWatcher")
+		return ctrl.Result{Requeue: true}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *CertificateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var certificatelogname string = req.Namespace + "/" + req.Name
+	var certificate = util.NewCertificate()
+	err := r.Get(ctx, req.NamespacedName, certificate)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			log.Info(certificatelogname + " Unable to get Certificate: " + err.Error())
+		} else {
+			log.Error(err, certificatelogname+" Unable to get Certificate")
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(nil)
+	}
+
+	var cwList certwatchv1.CertWatcherList
+	err = r.List(ctx, &cwList, client.MatchingFields{".spec.certificate.name": req.Name}, client.InNamespace(req.Namespace))
+	if err != nil {
+		log.Error(err, certificatelogname+" Unable to get CertWatcher list")
+		return ctrl.Result{Requeue: true}, err
+	}
+	if len(cwList.Items) == 0 {
+		return ctrl.Result{}, nil
+	}
+	state, err := util.GetCertificateState(certificate)
+	if err != nil {
+		log.Error(err, certificatelogname+" Unable to read Certificate")
+		return ctrl.Result{}, nil
+	}
+
+	var updateErr error
+	for _, cw := range cwList.Items {
+		if cw.Spec.Certificate == nil || cw.Spec.Certificate.Namespace != req.Namespace {
+			continue
+		}
+		if cw.Status.Status != "Ready" {
+			r.EventRecorder.Eventf(&cw, "Warning", "CertificateChanged", "Certificate changed, but CertWatcher not Ready.")
+			continue
+		}
+		var changed bool
+
+		// Surface readiness changes, issuance failures in particular.
+		var condition = util.CertificateReadyCondition(state, cw.Generation)
+		var previous = meta.FindStatusCondition(cw.Status.Conditions, condition.Type)
+		if previous == nil || previous.Status != condition.Status || previous.Reason != condition.Reason || previous.Message != condition.Message {
+			meta.SetStatusCondition(&cw.Status.Conditions, condition)
+			if state.Ready == apimachineryv1.ConditionFalse {
+				cw.Status.Message = "Certificate " + certificatelogname + " not Ready: " + state.Reason + ": " + state.Message
+				r.EventRecorder.Eventf(&cw, "Warning", "CertificateChanged", "Certificate not Ready: %s: %s", state.Reason, state.Message)
+			}
+			changed = true
+		}
+
+		if state.Ready == apimachineryv1.ConditionTrue && (cw.Status.Certificate == nil || cw.Status.Certificate.Revision != state.Revision) {
+			var secret corev1.Secret
+			err = r.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: state.SecretName}, &secret)
+			if err != nil {
+				log.Error(err, certificatelogname+" Unable to get Secret "+state.SecretName)
+				return ctrl.Result{Requeue: true}, err
+			}
+			dataChecksum, err := util.SecretChecksum(&secret, cw.Spec.Secret.Keys, cw.Spec.Checksum)
+			if err != nil {
+				log.Error(err, certificatelogname+" Unable to calculate Secret checksum")
+				return ctrl.Result{Requeue: true}, err
+			}
+			if cw.Status.ActionStatus == "Pending" {
+				r.EventRecorder.Eventf(&cw, "Warning", "CertificateChanged", "Certificate issued, but CertWatcher has Pending actions.")
+			}
+			cw.Status.Certificate = &certwatchv1.CertWatcherCertificateStatus{SecretName: state.SecretName, Revision: state.Revision}
+			cw.Status.LastChecksum = dataChecksum
+			cw.Status.Message = "Certificate revision issued"
+			cw.Status.ActionStatus = "Pending"
+			cw.Status.PendingActions = nil
+			r.EventRecorder.Eventf(&cw, "Normal", "CertificateChanged", "Certificate revision %d issued, updating CertWatcher status.", state.Revision)
+			changed = true
+		}
+
+		if changed {
+			if _, err = r.updateCertWatcher(ctx, &cw); err != nil {
+				updateErr = err
+			}
+		}
+	}
+	// Updates lost to conflicts with a stale cache are retried with the
+	// Certificate, whose revision is then compared again.
+	if updateErr != nil {
+		return ctrl.Result{Requeue: true}, updateErr
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager. Without
+// cert-manager installed, no controller is set up and Certificates cannot be
+// watched until the manager is restarted.
+func (r *CertificateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	var gvk = util.CertificateGroupVersionKind
+	if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			log.Info("cert-manager Certificates not available, watching Certificates disabled")
+			return nil
+		}
+		return err
+	}
+
+	var rateLimiter ratelimiter.RateLimiter = workqueue.NewItemFastSlowRateLimiter(retryFastDelay, retrySlowDelay, retryMaxFastAttempts)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(util.NewCertificate()).
+		WithOptions(controller.Options{RateLimiter: rateLimiter}).
+		Complete(r)
+}
@@ -0,0 +1,66 @@
+package certwatch
+
+import (
+	"context"
+	"fmt"
+
+	apicorev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
+)
+
+// getCertificateState reads the cert-manager Certificate watched by the
+// CertWatcher and records its readiness in the CertificateReady condition.
+func (r *CertWatcherReconciler) getCertificateState(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (util.CertificateState, error) {
+	var certificate = util.NewCertificate()
+	err := r.Get(ctx, types.NamespacedName{Namespace: certwatcher.Spec.Certificate.Namespace, Name: certwatcher.Spec.Certificate.Name}, certificate)
+	if err != nil {
+		return util.CertificateState{}, fmt.Errorf("unable to find Certificate %s: %s", sourceLogName(certwatcher), err.Error())
+	}
+	state, err := util.GetCertificateState(certificate)
+	if err != nil {
+		return state, err
+	}
+	meta.SetStatusCondition(&certwatcher.Status.Conditions, util.CertificateReadyCondition(state, certwatcher.Generation))
+	return state, nil
+}
+
+// initCertificate initializes a CertWatcher watching a cert-manager
+// Certificate, recording its current revision. Actions are performed once a
+// later revision is issued.
+func (r *CertWatcherReconciler) initCertificate(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (ctrl.Result, error) {
+	state, err := r.getCertificateState(ctx, certwatcher)
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherInit", "%s", err.Error())
+		certwatcher.Status.Message = err.Error()
+		return r.updateCertWatcher(ctx, certwatcher, err)
+	}
+	certwatcher.Status.Certificate = &certwatchv1.CertWatcherCertificateStatus{
+		SecretName: state.SecretName,
+		Revision:   state.Revision,
+	}
+
+	// The Secret does not exist before the first issuance.
+	certwatcher.Status.LastChecksum = ""
+	var secret apicorev1.Secret
+	err = r.Get(ctx, types.NamespacedName{Namespace: certwatcher.Spec.Certificate.Namespace, Name: state.SecretName}, &secret)
+	if err == nil {
+		certwatcher.Status.LastChecksum, err = util.SecretChecksum(&secret, certwatcher.Spec.Secret.Keys, certwatcher.Spec.Checksum)
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherInit", "Unable to read Secret %s/%s: %s", certwatcher.Spec.Certificate.Namespace, state.SecretName, err.Error())
+		certwatcher.Status.Message = "Unable to read Secret " + certwatcher.Spec.Certificate.Namespace + "/" + state.SecretName + ": " + err.Error()
+		return r.updateCertWatcher(ctx, certwatcher, err)
+	}
+
+	certwatcher.Status.Status = "Ready"
+	certwatcher.Status.Message = fmt.Sprintf("CertWatcher successfully initialized at Certificate revision %d", state.Revision)
+	certwatcher.Status.ActionStatus = ""
+	r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherInit", "CertWatcher successfully initialized at Certificate revision %d", state.Revision)
+	return r.updateCertWatcher(ctx, certwatcher, nil)
+}
@@ -2,6 +2,7 @@ package certwatch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -21,6 +23,7 @@ import (
 	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
 	"github.com/jhmorimoto/cert-watch/controllers/util"
 	"github.com/magiconair/properties"
+	"golang.org/x/crypto/openpgp"
 )
 
 var retryFastDelay = time.Second * time.Duration(5)
@@ -34,6 +37,13 @@ type CertWatcherReconciler struct {
 	Scheme             *runtime.Scheme
 	EmailConfiguration *properties.Properties
 	EventRecorder      record.EventRecorder
+	MailSender         *util.MailSender
+	EmailDigester      *util.EmailDigester
+
+	// MaxConcurrent is the number of CertWatchers processed at the same time,
+	// so a slow action, such as an e-mail being retried, does not hold back
+	// all other CertWatchers. Defaults to 1.
+	MaxConcurrent int
 }
 
 func (r *CertWatcherReconciler) updateCertWatcher(ctx context.Context, certwatcher *certwatchv1.CertWatcher, originalError error) (ctrl.Result, error) {
@@ -46,9 +56,303 @@ func (r *CertWatcherReconciler) updateCertWatcher(ctx context.Context, certwatch
 	return ctrl.Result{Requeue: originalError != nil}, originalError
 }
 
+// parseNamespacedName splits a reference in the form namespace/name.
+func parseNamespacedName(ref string) (types.NamespacedName, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return types.NamespacedName{}, fmt.Errorf("invalid reference %s, expected namespace/name", ref)
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+}
+
+// getSecretValue reads a single value from a Secret.
+func (r *CertWatcherReconciler) getSecretValue(ctx context.Context, ref *certwatchv1.CertWatcherSecretKeyRef) (string, error) {
+	name, err := parseNamespacedName(ref.Name)
+	if err != nil {
+		return "", err
+	}
+	var secret apicorev1.Secret
+	if err = r.Get(ctx, name, &secret); err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s does not have value for %s", ref.Name, ref.Key)
+	}
+	return string(value), nil
+}
+
+// certificateFilesOptions collects the settings of the CertWatcher that
+// control how certificate files are exported, reading passwords referenced
+// from Secrets. A random password replacing them is set by the caller.
+func (r *CertWatcherReconciler) certificateFilesOptions(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (util.CertificateFilesOptions, error) {
+	var err error
+	var options = util.CertificateFilesOptions{
+		FilenamesPrefix: certwatcher.Spec.FilenamesPrefix,
+		Formats:         certwatcher.Spec.Formats,
+		Zip:             util.ZipOptions{Password: certwatcher.Spec.ZipFilesPassword},
+		Pkcs12:          util.Pkcs12Options{Password: certwatcher.Spec.Pkcs12Password},
+		CertificateOnly: certwatcher.Spec.ConfigMap != nil || certwatcher.Spec.Endpoint != nil,
+	}
+	// Only e-mail, SCP and Job actions with files use files from the
+	// workspace directory.
+	if len(options.Formats) == 0 && certwatcher.Spec.Actions.Email == nil && certwatcher.Spec.Actions.Scp == nil &&
+		(certwatcher.Spec.Actions.Job == nil || len(certwatcher.Spec.Actions.Job.Files) == 0) {
+		options.Formats = []string{}
+	}
+	if certwatcher.Spec.ZipFilesPasswordSecretKeyRef != nil {
+		options.Zip.Password, err = r.getSecretValue(ctx, certwatcher.Spec.ZipFilesPasswordSecretKeyRef)
+		if err != nil {
+			return options, fmt.Errorf("zip files password: %s", err.Error())
+		}
+	}
+	if certwatcher.Spec.Pkcs12PasswordSecretKeyRef != nil {
+		options.Pkcs12.Password, err = r.getSecretValue(ctx, certwatcher.Spec.Pkcs12PasswordSecretKeyRef)
+		if err != nil {
+			return options, fmt.Errorf("pkcs12 password: %s", err.Error())
+		}
+	}
+	if certwatcher.Spec.RandomPassword != nil {
+		if err = validateRandomPassword(certwatcher); err != nil {
+			return options, err
+		}
+	}
+	if certwatcher.Spec.Zip != nil {
+		options.Zip.Encryption = certwatcher.Spec.Zip.Encryption
+		options.Zip.Compression = certwatcher.Spec.Zip.Compression
+		options.Zip.CompressionLevel = certwatcher.Spec.Zip.CompressionLevel
+	}
+	if certwatcher.Spec.Pkcs12 != nil {
+		options.Pkcs12.Profile = certwatcher.Spec.Pkcs12.Profile
+		options.Pkcs12.FriendlyName = certwatcher.Spec.Pkcs12.FriendlyName
+		options.Pkcs12.MacAlgorithm = certwatcher.Spec.Pkcs12.MacAlgorithm
+	}
+	if certwatcher.Spec.Keystore != nil {
+		options.Keystore.Alias = certwatcher.Spec.Keystore.Alias
+		if certwatcher.Spec.Keystore.PasswordSecretKeyRef != nil {
+			options.Keystore.Password, err = r.getSecretValue(ctx, certwatcher.Spec.Keystore.PasswordSecretKeyRef)
+			if err != nil {
+				return options, fmt.Errorf("keystore password: %s", err.Error())
+			}
+		}
+		if certwatcher.Spec.Keystore.TruststorePasswordSecretKeyRef != nil {
+			options.Keystore.TruststorePassword, err = r.getSecretValue(ctx, certwatcher.Spec.Keystore.TruststorePasswordSecretKeyRef)
+			if err != nil {
+				return options, fmt.Errorf("truststore password: %s", err.Error())
+			}
+		}
+	}
+	if certwatcher.Spec.KeyPasswordSecretKeyRef != nil {
+		options.KeyPassword, err = r.getSecretValue(ctx, certwatcher.Spec.KeyPasswordSecretKeyRef)
+		if err != nil {
+			return options, fmt.Errorf("key password: %s", err.Error())
+		}
+	}
+	return options, nil
+}
+
+// renderFileReferences renders the file name templates of the CertWatcher:
+// the filenames prefix and the files referenced by e-mail attachments, SCP
+// files and remote paths, and Job files. Templates are replaced in memory
+// only, as just the status of the CertWatcher is ever updated.
+func renderFileReferences(certwatcher *certwatchv1.CertWatcher, secret *apicorev1.Secret) error {
+	var err error
+	var spec = &certwatcher.Spec
+	var prefix string
+	var data *util.FilenameTemplateData
+	// Template data is only collected when templates are used, so a broken
+	// Secret is otherwise reported by CreateCertificateFiles as usual.
+	loadData := func() error {
+		if data == nil {
+			loaded, err := util.NewFilenameTemplateData(certwatcher, secret)
+			if err != nil {
+				return err
+			}
+			data = &loaded
+		}
+		data.Prefix = prefix
+		return nil
+	}
+	render := func(text string) (string, error) {
+		if !util.IsFilenameTemplate(text) {
+			return text, nil
+		}
+		if err := loadData(); err != nil {
+			return "", err
+		}
+		return util.RenderFilename(text, *data)
+	}
+
+	if util.IsFilenameTemplate(spec.FilenamesPrefix) {
+		if err = loadData(); err == nil {
+			spec.FilenamesPrefix, err = util.RenderFilenamesPrefix(spec.FilenamesPrefix, *data)
+		}
+		if err != nil {
+			return fmt.Errorf("filenamesPrefix: %s", err.Error())
+		}
+	}
+	prefix = spec.FilenamesPrefix
+	if prefix == "" {
+		prefix = "tls"
+	}
+
+	if spec.Actions.Email != nil {
+		for i, f := range spec.Actions.Email.Attachments {
+			if spec.Actions.Email.Attachments[i], err = render(f); err != nil {
+				return fmt.Errorf("EMAIL: attachment: %s", err.Error())
+			}
+		}
+	}
+	if spec.Actions.Scp != nil {
+		for i, f := range spec.Actions.Scp.Files {
+			if spec.Actions.Scp.Files[i].Name, err = render(f.Name); err != nil {
+				return fmt.Errorf("SCP: file: %s", err.Error())
+			}
+			if spec.Actions.Scp.Files[i].RemotePath, err = render(f.RemotePath); err != nil {
+				return fmt.Errorf("SCP: remotePath: %s", err.Error())
+			}
+		}
+	}
+	if spec.Actions.Job != nil {
+		for i, f := range spec.Actions.Job.Files {
+			if spec.Actions.Job.Files[i], err = render(f); err != nil {
+				return fmt.Errorf("JOB: file: %s", err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// validateCertificateFiles checks that every file referenced by the actions
+// of the CertWatcher is one that will be created in the workspace directory.
+// Job files must also be valid Secret keys.
+func validateCertificateFiles(certwatcher *certwatchv1.CertWatcher, options util.CertificateFilesOptions) error {
+	names, err := util.CertificateFileNames(options)
+	if err != nil {
+		return err
+	}
+	var available = map[string]bool{}
+	for _, name := range names {
+		available[name] = true
+	}
+	if certwatcher.Spec.Actions.Email != nil {
+		for _, f := range certwatcher.Spec.Actions.Email.Attachments {
+			if !available[f] {
+				return fmt.Errorf("EMAIL: attachment %s is not one of the certificate files created: %s", f, strings.Join(names, ", "))
+			}
+		}
+	}
+	if certwatcher.Spec.Actions.Scp != nil {
+		for _, f := range certwatcher.Spec.Actions.Scp.Files {
+			if !available[f.Name] {
+				return fmt.Errorf("SCP: file %s is not one of the certificate files created: %s", f.Name, strings.Join(names, ", "))
+			}
+		}
+	}
+	if certwatcher.Spec.Actions.Job != nil {
+		for _, f := range certwatcher.Spec.Actions.Job.Files {
+			if !available[f] {
+				return fmt.Errorf("JOB: file %s is not one of the certificate files created: %s", f, strings.Join(names, ", "))
+			}
+			if errs := validation.IsConfigMapKey(f); len(errs) > 0 {
+				return fmt.Errorf("JOB: file %s is not a valid Secret key: %s", f, strings.Join(errs, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// getPgpRecipients collects recipient public keys from the ConfigMap and/or
+// Secret referenced in the e-mail PGP configuration.
+func (r *CertWatcherReconciler) getPgpRecipients(ctx context.Context, pgp *certwatchv1.CertWatchEmailPgp) (openpgp.EntityList, error) {
+	var values = map[string][]byte{}
+	if pgp.PublicKeysConfigMap == "" && pgp.PublicKeysSecret == "" {
+		return nil, errors.New("PGP enabled, but neither publicKeysConfigMap nor publicKeysSecret were specified")
+	}
+	if pgp.PublicKeysConfigMap != "" {
+		name, err := parseNamespacedName(pgp.PublicKeysConfigMap)
+		if err != nil {
+			return nil, err
+		}
+		var configMap apicorev1.ConfigMap
+		if err = r.Get(ctx, name, &configMap); err != nil {
+			return nil, err
+		}
+		for k, v := range configMap.Data {
+			values[name.String()+"/"+k] = []byte(v)
+		}
+		for k, v := range configMap.BinaryData {
+			values[name.String()+"/"+k] = v
+		}
+	}
+	if pgp.PublicKeysSecret != "" {
+		name, err := parseNamespacedName(pgp.PublicKeysSecret)
+		if err != nil {
+			return nil, err
+		}
+		var secret apicorev1.Secret
+		if err = r.Get(ctx, name, &secret); err != nil {
+			return nil, err
+		}
+		for k, v := range secret.Data {
+			values[name.String()+"/"+k] = v
+		}
+	}
+	return util.ParsePgpPublicKeys(values)
+}
+
+// getEmailResources reads everything the e-mail action needs from other
+// Kubernetes resources: PGP recipient keys, inline images and OAuth2
+// credentials.
+func (r *CertWatcherReconciler) getEmailResources(ctx context.Context, email *certwatchv1.CertWatchActionEmail, emailConfig *properties.Properties) (util.EmailResources, error) {
+	var resources util.EmailResources
+	var err error
+	if email.Pgp != nil {
+		resources.PgpRecipients, err = r.getPgpRecipients(ctx, email.Pgp)
+		if err != nil {
+			return resources, err
+		}
+	}
+	if email.InlineImagesConfigMap != "" {
+		name, err := parseNamespacedName(email.InlineImagesConfigMap)
+		if err != nil {
+			return resources, err
+		}
+		var configMap apicorev1.ConfigMap
+		if err = r.Get(ctx, name, &configMap); err != nil {
+			return resources, err
+		}
+		resources.InlineImages = map[string][]byte{}
+		for k, v := range configMap.BinaryData {
+			resources.InlineImages[k] = v
+		}
+		for k, v := range configMap.Data {
+			resources.InlineImages[k] = []byte(v)
+		}
+	}
+	if emailConfig != nil && emailConfig.GetString("authentication", "") == "xoauth2" {
+		ref := emailConfig.GetString("oauth2.credentialSecret", "")
+		if ref == "" {
+			return resources, errors.New("missing email configuration value: oauth2.credentialSecret")
+		}
+		name, err := parseNamespacedName(ref)
+		if err != nil {
+			return resources, err
+		}
+		var secret apicorev1.Secret
+		if err = r.Get(ctx, name, &secret); err != nil {
+			return resources, err
+		}
+		resources.OAuth2Credentials = secret.Data
+	}
+	return resources, nil
+}
+
 //+kubebuilder:rbac:groups=certwatch.morimoto.net.br,resources=certwatchers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=certwatch.morimoto.net.br,resources=certwatchers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=certwatch.morimoto.net.br,resources=certwatchers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=create;update
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -72,24 +376,28 @@ func (r *CertWatcherReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, client.IgnoreNotFound(nil)
 	}
 
-	var secretlogname = certwatcher.Spec.Secret.Namespace + "/" + certwatcher.Spec.Secret.Name
-
 	// If Status is not Ready, then initiate this CertWatcher, update the Status
 	// and exit. Before initiation, no Secret changes will be processed.
 	if certwatcher.Status.Status != "Ready" {
 		certwatcher.Status.Status = "NotReady"
-		var secret apicorev1.Secret
-		var checksum string
-		err = r.Get(ctx, types.NamespacedName{Namespace: certwatcher.Spec.Secret.Namespace, Name: certwatcher.Spec.Secret.Name}, &secret)
-		if err != nil {
-			r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherInit", "Unable to find Secret %s: %s", secretlogname, err.Error())
-			certwatcher.Status.Message = "Unable to find Secret " + secretlogname + ": " + err.Error()
+		if err = validateSource(&certwatcher); err != nil {
+			r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherInit", "%s", err.Error())
+			certwatcher.Status.Message = err.Error()
 			return r.updateCertWatcher(ctx, &certwatcher, err)
 		}
-		checksum, err = util.SecretDataChecksum(&secret)
+		if certwatcher.Spec.Secret.Selector != nil {
+			return r.initSelectedSecrets(ctx, &certwatcher)
+		}
+		if certwatcher.Spec.Certificate != nil {
+			return r.initCertificate(ctx, &certwatcher)
+		}
+		if certwatcher.Spec.Endpoint != nil {
+			return r.initEndpoint(ctx, &certwatcher)
+		}
+		checksum, err := r.sourceChecksum(ctx, &certwatcher)
 		if err != nil {
-			r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherInit", "calculate secret checksum %s: %s", secretlogname, err.Error())
-			certwatcher.Status.Message = "Unable to calculate Secret checksum " + secretlogname + ": " + err.Error()
+			r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherInit", "%s", err.Error())
+			certwatcher.Status.Message = err.Error()
 			return r.updateCertWatcher(ctx, &certwatcher, err)
 		}
 		certwatcher.Status.LastChecksum = checksum
@@ -100,98 +408,243 @@ func (r *CertWatcherReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return r.updateCertWatcher(ctx, &certwatcher, nil)
 	}
 
+	// The Secret was deleted, and its onDelete actions are still to be
+	// performed.
+	if certwatcher.Status.SecretDeleted != nil && certwatcher.Status.SecretDeleted.ActionStatus == "Pending" {
+		return r.processOnDelete(ctx, &certwatcher)
+	}
+	// Nothing else is performed until the Secret is created again. Actions
+	// still Pending are then performed or dropped, as set in onRecreate.
+	if certwatcher.Status.SecretDeleted != nil {
+		return ctrl.Result{}, nil
+	}
+
+	// A run requested with the run annotation is processed like a change of
+	// the Secret, and the annotation removed once it is completed.
+	if certwatcher.Status.ActionStatus != "Pending" {
+		if cleared, err := r.clearRunAnnotation(ctx, &certwatcher); cleared || err != nil {
+			return ctrl.Result{Requeue: err != nil}, err
+		}
+		if r.requestRun(&certwatcher) {
+			return r.updateCertWatcher(ctx, &certwatcher, nil)
+		}
+		if certwatcher.Generation != certwatcher.Status.ObservedGeneration {
+			return r.specChanged(ctx, &certwatcher)
+		}
+	}
+
+	// A digest e-mail still Queued, but no longer buffered, was lost in a
+	// controller restart. Queue it again, as well as a digest e-mail that
+	// failed, once its retry delay has elapsed.
+	if certwatcher.Status.ActionStatus != "Pending" {
+		if r.emailDigestLost(&certwatcher) {
+			return r.requeueEmailDigest(ctx, &certwatcher)
+		}
+		if retrying, wait := r.emailDigestRetrying(&certwatcher); retrying {
+			if wait > 0 {
+				return ctrl.Result{RequeueAfter: wait}, nil
+			}
+			return r.requeueEmailDigest(ctx, &certwatcher)
+		}
+	}
+
 	// If ActionStatus is Pending, then process all actions and change the
 	// Status back to Ready.
 	if certwatcher.Status.ActionStatus == "Pending" {
 		r.EventRecorder.Eventf(&certwatcher, "Normal", "CertWatcherProcessing", "Processing pending actions")
-		var secret apicorev1.Secret
-		var certFilesDir string
-		err = r.Get(ctx, types.NamespacedName{Namespace: certwatcher.Spec.Secret.Namespace, Name: certwatcher.Spec.Secret.Name}, &secret)
-		if err != nil {
-			r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing", "Unable to find Secret for processing %s", secretlogname)
-			certwatcher.Status.Message = "Unable to find Secret for processing" + secretlogname + ": " + err.Error()
-			return r.updateCertWatcher(ctx, &certwatcher, err)
+		if certwatcher.Spec.ZipFilesPassword != "" {
+			r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing", "zipFilesPassword is deprecated, use zipFilesPasswordSecretKeyRef instead")
 		}
-
-		certFilesDir, err = util.CreateCertificateFiles(&secret, certwatcher.Spec.FilenamesPrefix, certwatcher.Spec.ZipFilesPassword, certwatcher.Spec.Pkcs12Password)
-		defer func() {
-			err := os.RemoveAll(certFilesDir)
-			if err != nil {
-				log.Error(err, "Error removing temporary workspace directory: "+certFilesDir)
-			}
-		}()
-		if err != nil {
+		if certwatcher.Spec.Pkcs12Password != "" {
+			r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing", "pkcs12Password is deprecated, use pkcs12PasswordSecretKeyRef instead")
+		}
+		// Actions are performed for the current spec, so a spec change
+		// meanwhile needs no further actions.
+		if err = observeSpec(&certwatcher); err != nil {
 			r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing", "%s", err.Error())
 			certwatcher.Status.Message = err.Error()
 			return r.updateCertWatcher(ctx, &certwatcher, err)
 		}
+		limitActions(&certwatcher)
+		if certwatcher.Spec.Secret.Selector != nil {
+			return r.processSelectedSecrets(ctx, &certwatcher)
+		}
+		if err = r.processActions(ctx, &certwatcher); err != nil {
+			return r.updateCertWatcher(ctx, &certwatcher, err)
+		}
 
-		if certwatcher.Spec.Actions.Echo != nil {
-			r.EventRecorder.Eventf(&certwatcher, "Normal", "CertWatcherProcessing", "ECHO: Good morning to %s", secretlogname)
+		certwatcher.Status.ActionStatus = "Ready"
+		certwatcher.Status.Message = "Waiting for next Secret change"
+		certwatcher.Status.PendingActions = nil
+		completeRun(&certwatcher)
+		r.EventRecorder.Eventf(&certwatcher, "Normal", "CertWatcherProcessing", "Action processing finished successfully")
+		return r.updateCertWatcher(ctx, &certwatcher, nil)
+	}
+
+	// Remote endpoints have no events to watch, so they are checked
+	// periodically.
+	if certwatcher.Spec.Endpoint != nil {
+		return r.reconcileEndpoint(ctx, &certwatcher)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// processActions performs all actions of the CertWatcher for the current
+// contents of its Secret. When an action fails, an event is recorded, the
+// status message is set and the error is returned, so the CertWatcher can be
+// updated and processed again.
+func (r *CertWatcherReconciler) processActions(ctx context.Context, certwatcher *certwatchv1.CertWatcher) error {
+	var secretlogname = sourceLogName(certwatcher)
+	var certFilesDir string
+	secret, err := r.getSource(ctx, certwatcher)
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "%s", err.Error())
+		certwatcher.Status.Message = err.Error()
+		return err
+	}
+
+	if err = r.validateCertificate(ctx, certwatcher, secret); err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "Certificate validation failed, actions not performed: %s", err.Error())
+		certwatcher.Status.Message = "Certificate validation failed, actions not performed: " + err.Error()
+		return err
+	}
+
+	var filesOptions util.CertificateFilesOptions
+	err = renderFileReferences(certwatcher, secret)
+	if err == nil {
+		filesOptions, err = r.certificateFilesOptions(ctx, certwatcher)
+	}
+	if err == nil {
+		err = validateCertificateFiles(certwatcher, filesOptions)
+	}
+	if err == nil && certwatcher.Spec.RandomPassword != nil {
+		filesOptions.Zip.Password, err = r.randomPassword(ctx, certwatcher)
+		filesOptions.Pkcs12.Password = filesOptions.Zip.Password
+	}
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "%s", err.Error())
+		certwatcher.Status.Message = err.Error()
+		return err
+	}
+
+	certFilesDir, err = util.CreateCertificateFiles(secret, filesOptions)
+	defer func() {
+		err := os.RemoveAll(certFilesDir)
+		if err != nil {
+			log.Error(err, "Error removing temporary workspace directory: "+certFilesDir)
 		}
+	}()
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "%s", err.Error())
+		certwatcher.Status.Message = err.Error()
+		return err
+	}
 
-		if certwatcher.Spec.Actions.Email != nil {
-			var emailConfig = r.EmailConfiguration
-			if certwatcher.Spec.Actions.Email.ConfigFile != "" {
-				emailConfig = properties.MustLoadFile(certwatcher.Spec.Actions.Email.ConfigFile, properties.UTF8)
-			}
-			r.EventRecorder.Eventf(&certwatcher, "Normal", "CertWatcherProcessing", "EMAIL: Sending mail to %s via %s:%d", certwatcher.Spec.Actions.Email.To, emailConfig.GetString("host", ""), emailConfig.GetInt("port", 0))
-			err = util.ProcessEmail(&certwatcher, certFilesDir, emailConfig)
-			if err != nil {
-				r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: %s", err.Error())
-				certwatcher.Status.Message = err.Error()
-				return r.updateCertWatcher(ctx, &certwatcher, err)
-			}
+	if certwatcher.Spec.RandomPassword != nil {
+		if err = r.deliverRandomPassword(ctx, certwatcher, filesOptions.Zip.Password); err != nil {
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "%s", err.Error())
+			certwatcher.Status.Message = err.Error()
+			return err
 		}
-		if certwatcher.Spec.Actions.Scp != nil {
-			if certwatcher.Spec.Actions.Scp.Port == 0 {
-				certwatcher.Spec.Actions.Scp.Port = 22
-			}
-			var credentialSecret apicorev1.Secret
-			var credentialSecretName = strings.Split(certwatcher.Spec.Actions.Scp.CredentialSecret, "/")
-			if len(credentialSecretName) < 2 {
-				r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing",
-					"SCP: Invalid credentialSecret naming format %s", certwatcher.Spec.Actions.Scp.CredentialSecret)
-				certwatcher.Status.Message = fmt.Sprintf("SCP: Invalid credentialSecret naming format %s",
-					certwatcher.Spec.Actions.Scp.CredentialSecret)
-				return r.updateCertWatcher(ctx, &certwatcher, err)
-			}
-			err = r.Get(ctx, types.NamespacedName{Namespace: credentialSecretName[0], Name: credentialSecretName[1]}, &credentialSecret)
+		r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "PASSWORD: Random password delivered")
+	}
+
+	if certwatcher.Spec.Actions.Echo != nil {
+		r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "ECHO: Good morning to %s", secretlogname)
+	}
+
+	if certwatcher.Spec.Actions.Email != nil {
+		var emailConfig = r.emailConfiguration(certwatcher.Spec.Actions.Email)
+		var emailResources util.EmailResources
+		emailResources, err = r.getEmailResources(ctx, certwatcher.Spec.Actions.Email, emailConfig)
+		if err != nil {
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: %s", err.Error())
+			certwatcher.Status.Message = fmt.Sprintf("EMAIL: %s", err.Error())
+			return err
+		}
+		if certwatcher.Spec.Actions.Email.Digest != nil {
+			err = r.queueEmailDigest(certwatcher, certFilesDir, emailConfig, emailResources)
 			if err != nil {
-				r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing", "SCP: %s", err.Error())
-				certwatcher.Status.Message = fmt.Sprintf("SCP: %s", err.Error())
-				return r.updateCertWatcher(ctx, &certwatcher, err)
+				r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: %s", err.Error())
+				certwatcher.Status.Message = err.Error()
+				return err
 			}
-			r.EventRecorder.Eventf(&certwatcher, "Normal", "CertWatcherProcessing", "SCP: Sending files to %s:%d", certwatcher.Spec.Actions.Scp.Hostname, certwatcher.Spec.Actions.Scp.Port)
-			err = util.ProcessScp(&certwatcher, credentialSecret, certFilesDir)
+			r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "EMAIL: Queued for digest to %s", certwatcher.Spec.Actions.Email.To)
+		} else {
+			r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "EMAIL: Sending mail to %s via %s:%d", certwatcher.Spec.Actions.Email.To, emailConfig.GetString("host", ""), emailConfig.GetInt("port", 0))
+			err = util.ProcessEmail(ctx, r.MailSender, certwatcher, certFilesDir, emailConfig, emailResources)
 			if err != nil {
-				r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing", "SCP: %s", err.Error())
+				r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: %s", err.Error())
 				certwatcher.Status.Message = err.Error()
-				return r.updateCertWatcher(ctx, &certwatcher, err)
+				return err
 			}
 		}
-		if certwatcher.Spec.Actions.Job != nil {
-			job, err := util.ProcessJob(&certwatcher)
+	}
+	if certwatcher.Spec.Actions.Scp != nil {
+		if certwatcher.Spec.Actions.Scp.Port == 0 {
+			certwatcher.Spec.Actions.Scp.Port = 22
+		}
+		var credentialSecret apicorev1.Secret
+		var credentialSecretName = strings.Split(certwatcher.Spec.Actions.Scp.CredentialSecret, "/")
+		if len(credentialSecretName) < 2 {
+			err = fmt.Errorf("SCP: Invalid credentialSecret naming format %s", certwatcher.Spec.Actions.Scp.CredentialSecret)
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "%s", err.Error())
+			certwatcher.Status.Message = err.Error()
+			return err
+		}
+		err = r.Get(ctx, types.NamespacedName{Namespace: credentialSecretName[0], Name: credentialSecretName[1]}, &credentialSecret)
+		if err != nil {
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "SCP: %s", err.Error())
+			certwatcher.Status.Message = fmt.Sprintf("SCP: %s", err.Error())
+			return err
+		}
+		r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "SCP: Sending files to %s:%d", certwatcher.Spec.Actions.Scp.Hostname, certwatcher.Spec.Actions.Scp.Port)
+		err = util.ProcessScp(certwatcher, credentialSecret, certFilesDir)
+		if err != nil {
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "SCP: %s", err.Error())
+			certwatcher.Status.Message = err.Error()
+			return err
+		}
+	}
+	if certwatcher.Spec.Actions.Job != nil {
+		job, err := util.ProcessJob(certwatcher)
+		if err != nil {
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "JOB Error preparing new job: %s", err.Error())
+			certwatcher.Status.Message = err.Error()
+			return err
+		}
+		// The files Secret is prepared before the Job is created, so missing
+		// files do not leave behind a Job waiting for a Secret that never comes.
+		var filesSecret *apicorev1.Secret
+		if len(certwatcher.Spec.Actions.Job.Files) > 0 {
+			filesSecret, err = util.JobFilesSecret(certwatcher, job, certFilesDir)
 			if err != nil {
-				r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing", "JOB Error preparing new job: %s", err.Error())
+				r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "JOB: Error creating files secret: %s", err.Error())
 				certwatcher.Status.Message = err.Error()
-				return r.updateCertWatcher(ctx, &certwatcher, err)
+				return err
 			}
-			err = r.Create(ctx, job)
-			if err != nil {
-				r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing", "JOB: Error creating new job%s", err.Error())
+		}
+		err = r.Create(ctx, job)
+		if err != nil {
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "JOB: Error creating new job%s", err.Error())
+			certwatcher.Status.Message = err.Error()
+			return err
+		}
+		if filesSecret != nil {
+			// The Secret is owned by the Job, whose UID is only known now.
+			filesSecret.OwnerReferences[0].UID = job.UID
+			if err = r.Create(ctx, filesSecret); err != nil {
+				r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "JOB: Error creating files secret: %s", err.Error())
 				certwatcher.Status.Message = err.Error()
-				return r.updateCertWatcher(ctx, &certwatcher, err)
+				if deleteErr := r.Delete(ctx, job, client.PropagationPolicy(apimachineryv1.DeletePropagationBackground)); deleteErr != nil {
+					log.Error(deleteErr, "Unable to delete Job "+job.Namespace+"/"+job.Name+" without its files secret")
+				}
+				return err
 			}
 		}
-
-		certwatcher.Status.ActionStatus = "Ready"
-		certwatcher.Status.Message = "Waiting for next Secret change"
-		r.EventRecorder.Eventf(&certwatcher, "Normal", "CertWatcherProcessing", "Action processing finished successfully")
-		return r.updateCertWatcher(ctx, &certwatcher, nil)
 	}
-
-	return ctrl.Result{}, nil
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -209,6 +662,32 @@ func (r *CertWatcherReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
+	// Create index for .spec.configMap.name
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &certwatchv1.CertWatcher{}, ".spec.configMap.name", func(rawObj client.Object) []string {
+		cw := rawObj.(*certwatchv1.CertWatcher)
+		if cw.Spec.ConfigMap == nil {
+			return nil
+		}
+		return []string{cw.Spec.ConfigMap.Name}
+	})
+	if err != nil {
+		log.Error(err, "Unable to create index for .spec.configMap.name")
+		return err
+	}
+
+	// Create index for .spec.certificate.name
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &certwatchv1.CertWatcher{}, ".spec.certificate.name", func(rawObj client.Object) []string {
+		cw := rawObj.(*certwatchv1.CertWatcher)
+		if cw.Spec.Certificate == nil {
+			return nil
+		}
+		return []string{cw.Spec.Certificate.Name}
+	})
+	if err != nil {
+		log.Error(err, "Unable to create index for .spec.certificate.name")
+		return err
+	}
+
 	// Create index for .spec.secret.namespace
 	err = mgr.GetFieldIndexer().IndexField(context.Background(), &certwatchv1.CertWatcher{}, ".spec.secret.namespace", func(rawObj client.Object) []string {
 		cw := rawObj.(*certwatchv1.CertWatcher)
@@ -219,10 +698,21 @@ func (r *CertWatcherReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
+	// E-mail digests are buffered by an EmailDigester running along with the
+	// manager, which reports deliveries back to this reconciler.
+	if r.EmailDigester == nil {
+		r.EmailDigester = &util.EmailDigester{Sender: r.MailSender}
+	}
+	r.EmailDigester.OnDelivered = r.emailDigestDelivered
+	if err = mgr.Add(r.EmailDigester); err != nil {
+		log.Error(err, "Unable to add e-mail digester")
+		return err
+	}
+
 	var rateLimiter ratelimiter.RateLimiter = workqueue.NewItemFastSlowRateLimiter(retryFastDelay, retrySlowDelay, retryMaxFastAttempts)
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&certwatchv1.CertWatcher{}).
-		WithOptions(controller.Options{RateLimiter: rateLimiter}).
+		WithOptions(controller.Options{RateLimiter: rateLimiter, MaxConcurrentReconciles: r.MaxConcurrent}).
 		Complete(r)
 }
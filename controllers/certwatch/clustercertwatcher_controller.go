@@ -0,0 +1,259 @@
+package certwatch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apicorev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+)
+
+var clusterlog = ctrl.Log.WithName("ClusterCertWatcherController")
+
+// ClusterCertWatcherReconciler reconciles a ClusterCertWatcher object. Instead
+// of performing actions itself, it creates a CertWatcher with a Secret
+// selector in each selected namespace, so actions are performed by the
+// CertWatcherReconciler, and summarizes their statuses.
+type ClusterCertWatcherReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=certwatch.morimoto.net.br,resources=clustercertwatchers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=certwatch.morimoto.net.br,resources=clustercertwatchers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=certwatch.morimoto.net.br,resources=clustercertwatchers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+func (r *ClusterCertWatcherReconciler) updateClusterCertWatcher(ctx context.Context, clustercertwatcher *certwatchv1.ClusterCertWatcher, originalError error) (ctrl.Result, error) {
+	clustercertwatcher.Status.LastUpdate = apimachineryv1.Now()
+	if err := r.Status().Update(ctx, clustercertwatcher); err != nil {
+		r.EventRecorder.Eventf(clustercertwatcher, "Warning", "ClusterCertWatcherFailure", "Unable update ClusterCertWatcher: %s", err.Error())
+		return ctrl.Result{Requeue: true}, err
+	}
+	return ctrl.Result{Requeue: originalError != nil}, originalError
+}
+
+// labelSelectorAsSelector converts a label selector, matching everything
+// when it is empty.
+func labelSelectorAsSelector(selector *apimachineryv1.LabelSelector) (labels.Selector, error) {
+	if selector == nil {
+		return labels.Everything(), nil
+	}
+	return apimachineryv1.LabelSelectorAsSelector(selector)
+}
+
+func (r *ClusterCertWatcherReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var clustercertwatcher certwatchv1.ClusterCertWatcher
+	err := r.Get(ctx, req.NamespacedName, &clustercertwatcher)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			clusterlog.Info(req.Name + " Unable to get ClusterCertWatcher: " + err.Error())
+		} else {
+			clusterlog.Error(err, req.Name+" Unable to get ClusterCertWatcher")
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(nil)
+	}
+
+	namespaceSelector, err := labelSelectorAsSelector(clustercertwatcher.Spec.NamespaceSelector)
+	if err != nil {
+		err = fmt.Errorf("invalid namespace selector: %s", err.Error())
+	} else {
+		_, err = labelSelectorAsSelector(clustercertwatcher.Spec.SecretSelector)
+		if err != nil {
+			err = fmt.Errorf("invalid secret selector: %s", err.Error())
+		}
+	}
+	if err != nil {
+		r.EventRecorder.Eventf(&clustercertwatcher, "Warning", "ClusterCertWatcherInit", "%s", err.Error())
+		clustercertwatcher.Status.Status = "NotReady"
+		clustercertwatcher.Status.Message = err.Error()
+		return r.updateClusterCertWatcher(ctx, &clustercertwatcher, err)
+	}
+
+	var namespaceList apicorev1.NamespaceList
+	err = r.List(ctx, &namespaceList, client.MatchingLabelsSelector{Selector: namespaceSelector})
+	if err != nil {
+		r.EventRecorder.Eventf(&clustercertwatcher, "Warning", "ClusterCertWatcherInit", "Unable to list namespaces: %s", err.Error())
+		clustercertwatcher.Status.Status = "NotReady"
+		clustercertwatcher.Status.Message = "Unable to list namespaces: " + err.Error()
+		return r.updateClusterCertWatcher(ctx, &clustercertwatcher, err)
+	}
+
+	// Create or update the CertWatcher of each selected namespace. A failure
+	// in one namespace does not prevent the others.
+	var selected = make(map[string]bool)
+	var failures []certwatchv1.ClusterCertWatcherCertWatcherStatus
+	var lastErr error
+	for _, namespace := range namespaceList.Items {
+		if namespace.Status.Phase == apicorev1.NamespaceTerminating {
+			continue
+		}
+		selected[namespace.Name] = true
+		if err = r.syncCertWatcher(ctx, &clustercertwatcher, namespace.Name); err != nil {
+			r.EventRecorder.Eventf(&clustercertwatcher, "Warning", "ClusterCertWatcherProcessing", "%s", err.Error())
+			failures = append(failures, certwatchv1.ClusterCertWatcherCertWatcherStatus{
+				Namespace: namespace.Name,
+				Status:    "NotReady",
+				Message:   err.Error(),
+			})
+			lastErr = err
+		}
+	}
+
+	// Remove the CertWatchers of namespaces no longer selected, and summarize
+	// the others.
+	var certwatcherList certwatchv1.CertWatcherList
+	err = r.List(ctx, &certwatcherList, client.MatchingLabels{certwatchv1.ClusterCertWatcherLabel: clustercertwatcher.Name})
+	if err != nil {
+		r.EventRecorder.Eventf(&clustercertwatcher, "Warning", "ClusterCertWatcherProcessing", "Unable to list CertWatchers: %s", err.Error())
+		clustercertwatcher.Status.Status = "NotReady"
+		clustercertwatcher.Status.Message = "Unable to list CertWatchers: " + err.Error()
+		return r.updateClusterCertWatcher(ctx, &clustercertwatcher, err)
+	}
+	var status = certwatchv1.ClusterCertWatcherStatus{Namespaces: len(selected)}
+	for _, certwatcher := range certwatcherList.Items {
+		if !apimachineryv1.IsControlledBy(&certwatcher, &clustercertwatcher) {
+			continue
+		}
+		if !selected[certwatcher.Namespace] {
+			err = r.Delete(ctx, &certwatcher)
+			if client.IgnoreNotFound(err) != nil {
+				r.EventRecorder.Eventf(&clustercertwatcher, "Warning", "ClusterCertWatcherProcessing", "Unable to delete CertWatcher %s/%s: %s", certwatcher.Namespace, certwatcher.Name, err.Error())
+				lastErr = err
+			} else {
+				r.EventRecorder.Eventf(&clustercertwatcher, "Normal", "ClusterCertWatcherProcessing", "Namespace %s no longer selected, CertWatcher deleted", certwatcher.Namespace)
+			}
+			continue
+		}
+		var pending int
+		for _, secret := range certwatcher.Status.Secrets {
+			if secret.ActionStatus == "Pending" {
+				pending++
+			}
+		}
+		status.Secrets += len(certwatcher.Status.Secrets)
+		status.PendingSecrets += pending
+		if certwatcher.Status.Status != "Ready" || certwatcher.Status.ActionStatus == "Pending" {
+			status.CertWatchers = append(status.CertWatchers, certwatchv1.ClusterCertWatcherCertWatcherStatus{
+				Namespace:      certwatcher.Namespace,
+				Status:         certwatcher.Status.Status,
+				ActionStatus:   certwatcher.Status.ActionStatus,
+				PendingSecrets: pending,
+				Message:        certwatcher.Status.Message,
+			})
+		}
+	}
+	status.CertWatchers = append(status.CertWatchers, failures...)
+
+	clustercertwatcher.Status = status
+	if lastErr != nil {
+		clustercertwatcher.Status.Status = "NotReady"
+		clustercertwatcher.Status.Message = lastErr.Error()
+		return r.updateClusterCertWatcher(ctx, &clustercertwatcher, lastErr)
+	}
+	clustercertwatcher.Status.Status = "Ready"
+	clustercertwatcher.Status.Message = fmt.Sprintf("Watching %d Secret(s) in %d namespace(s)", status.Secrets, status.Namespaces)
+	return r.updateClusterCertWatcher(ctx, &clustercertwatcher, nil)
+}
+
+// syncCertWatcher creates or updates the CertWatcher of the
+// ClusterCertWatcher in a namespace. It has the same name as the
+// ClusterCertWatcher, and an existing CertWatcher by that name not created by
+// it is left untouched.
+func (r *ClusterCertWatcherReconciler) syncCertWatcher(ctx context.Context, clustercertwatcher *certwatchv1.ClusterCertWatcher, namespace string) error {
+	var certwatcher = &certwatchv1.CertWatcher{
+		ObjectMeta: apimachineryv1.ObjectMeta{Namespace: namespace, Name: clustercertwatcher.Name},
+	}
+	var conflictErr error
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, certwatcher, func() error {
+		if !certwatcher.CreationTimestamp.IsZero() && !apimachineryv1.IsControlledBy(certwatcher, clustercertwatcher) {
+			conflictErr = fmt.Errorf("CertWatcher %s/%s already exists and does not belong to ClusterCertWatcher %s", namespace, certwatcher.Name, clustercertwatcher.Name)
+			return conflictErr
+		}
+		if certwatcher.Labels == nil {
+			certwatcher.Labels = make(map[string]string)
+		}
+		certwatcher.Labels[certwatchv1.ClusterCertWatcherLabel] = clustercertwatcher.Name
+		var secretSelector = clustercertwatcher.Spec.SecretSelector.DeepCopy()
+		if secretSelector == nil {
+			secretSelector = &apimachineryv1.LabelSelector{}
+		}
+		certwatcher.Spec.Secret = certwatchv1.CertWatcherSecret{
+			Namespace: namespace,
+			Selector:  secretSelector,
+			Keys:      clustercertwatcher.Spec.SecretKeys.DeepCopy(),
+		}
+		clustercertwatcher.Spec.CertWatcherSettings.DeepCopyInto(&certwatcher.Spec.CertWatcherSettings)
+		return controllerutil.SetControllerReference(clustercertwatcher, certwatcher, r.Scheme)
+	})
+	if conflictErr != nil {
+		return conflictErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to create or update CertWatcher %s/%s: %s", namespace, certwatcher.Name, err.Error())
+	}
+	if result == controllerutil.OperationResultCreated {
+		r.EventRecorder.Eventf(clustercertwatcher, "Normal", "ClusterCertWatcherProcessing", "CertWatcher created in namespace %s", namespace)
+	}
+	return nil
+}
+
+// namespaceClusterCertWatchers maps a Namespace to all ClusterCertWatchers,
+// since a change of its labels may select or deselect it for any of them.
+func (r *ClusterCertWatcherReconciler) namespaceClusterCertWatchers(obj client.Object) []reconcile.Request {
+	var list certwatchv1.ClusterCertWatcherList
+	if err := r.List(context.Background(), &list); err != nil {
+		clusterlog.Error(err, obj.GetName()+" Unable to get ClusterCertWatcher list")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, clustercertwatcher := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: clustercertwatcher.Name}})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager. Without the
+// ClusterCertWatcher CRD installed, e.g. by an older chart, no controller is
+// set up and ClusterCertWatchers are ignored until the manager is restarted.
+func (r *ClusterCertWatcherReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	var gvk = certwatchv1.GroupVersion.WithKind("ClusterCertWatcher")
+	if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			clusterlog.Info("ClusterCertWatcher CRD not installed, watching ClusterCertWatchers disabled")
+			return nil
+		}
+		return err
+	}
+
+	var rateLimiter ratelimiter.RateLimiter = workqueue.NewItemFastSlowRateLimiter(retryFastDelay, retrySlowDelay, retryMaxFastAttempts)
+
+	// Status updates of the ClusterCertWatcher itself are ignored, while
+	// those of its CertWatchers refresh the summary.
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&certwatchv1.ClusterCertWatcher{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&certwatchv1.CertWatcher{}).
+		Watches(&source.Kind{Type: &apicorev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.namespaceClusterCertWatchers)).
+		WithOptions(controller.Options{RateLimiter: rateLimiter}).
+		Complete(r)
+}
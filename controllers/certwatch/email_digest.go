@@ -0,0 +1,238 @@
+package certwatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
+	"github.com/magiconair/properties"
+)
+
+var emailDigestRetryDelay = time.Minute
+var emailDigestMaxRetryDelay = time.Hour
+var emailDigestMaxAttempts = 10
+
+// emailConfiguration returns the email configuration used by the e-mail
+// action of the CertWatcher.
+func (r *CertWatcherReconciler) emailConfiguration(email *certwatchv1.CertWatchActionEmail) *properties.Properties {
+	if email.ConfigFile != "" {
+		return properties.MustLoadFile(email.ConfigFile, properties.UTF8)
+	}
+	return r.EmailConfiguration
+}
+
+// queueEmailDigest buffers the current Secret change in the EmailDigester,
+// reading attachments from the temporary workspace directory, and marks the
+// notification as Queued in the CertWatcher status. Without a workspace
+// directory, the notification is queued without attachments.
+func (r *CertWatcherReconciler) queueEmailDigest(certwatcher *certwatchv1.CertWatcher, certFilesDir string, emailConfig *properties.Properties, resources util.EmailResources) error {
+	var spec = certwatcher.Spec.Actions.Email
+	var attachments = map[string][]byte{}
+	if !spec.Digest.OmitAttachments && certFilesDir != "" {
+		for _, f := range spec.Attachments {
+			data, err := os.ReadFile(filepath.Join(certFilesDir, f))
+			if err != nil {
+				return err
+			}
+			attachments[f] = data
+		}
+	}
+
+	var attempts int
+	if previous := certwatcher.Status.EmailDigest; previous != nil && previous.Checksum == certwatcher.Status.LastChecksum {
+		attempts = previous.Attempts
+	}
+	now := apimachineryv1.Now()
+	r.EmailDigester.Add(certwatcher, emailConfig, resources, util.EmailDigestEntry{
+		CertWatcher: types.NamespacedName{Namespace: certwatcher.Namespace, Name: certwatcher.Name},
+		Secret:      sourceLogName(certwatcher),
+		Checksum:    certwatcher.Status.LastChecksum,
+		Time:        now.Time,
+		Subject:     spec.Subject,
+		Attachments: attachments,
+	})
+	certwatcher.Status.EmailDigest = &certwatchv1.CertWatcherEmailDigestStatus{
+		State:    util.EmailDigestQueued,
+		Checksum: certwatcher.Status.LastChecksum,
+		Source:   sourceLogName(certwatcher),
+		QueuedAt: now,
+		Attempts: attempts,
+	}
+	return nil
+}
+
+// emailDigestLost reports whether the CertWatcher has a Queued digest
+// notification that is no longer buffered, which happens when the controller
+// restarts before the digest window expires.
+func (r *CertWatcherReconciler) emailDigestLost(certwatcher *certwatchv1.CertWatcher) bool {
+	var digest = certwatcher.Status.EmailDigest
+	if digest == nil || digest.State != util.EmailDigestQueued || r.EmailDigester == nil {
+		return false
+	}
+	if certwatcher.Spec.Actions.Email == nil || certwatcher.Spec.Actions.Email.Digest == nil {
+		return false
+	}
+	return !r.EmailDigester.Has(types.NamespacedName{Namespace: certwatcher.Namespace, Name: certwatcher.Name}, digest.Checksum)
+}
+
+// emailDigestRetrying reports whether the CertWatcher has a digest
+// notification that failed to be sent and is to be retried, along with how
+// long to wait before queueing it again.
+func (r *CertWatcherReconciler) emailDigestRetrying(certwatcher *certwatchv1.CertWatcher) (bool, time.Duration) {
+	var digest = certwatcher.Status.EmailDigest
+	if digest == nil || digest.State != util.EmailDigestRetrying || r.EmailDigester == nil {
+		return false, 0
+	}
+	if certwatcher.Spec.Actions.Email == nil || certwatcher.Spec.Actions.Email.Digest == nil {
+		return false, 0
+	}
+	var delay = emailDigestRetryDelay
+	for i := 1; i < digest.Attempts && delay < emailDigestMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > emailDigestMaxRetryDelay {
+		delay = emailDigestMaxRetryDelay
+	}
+	if digest.FailedAt == nil {
+		return true, 0
+	}
+	return true, delay - time.Since(digest.FailedAt.Time)
+}
+
+// requeueEmailDigest queues the digest notification of a CertWatcher again,
+// recreating its attachments from the current Secret contents. Files
+// protected with a random password are created with the password already
+// delivered, read back from the password Secret. When the password Secret no
+// longer holds it, the notification is queued without attachments.
+func (r *CertWatcherReconciler) requeueEmailDigest(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (ctrl.Result, error) {
+	// With a selector, the digest was queued for one of the selected Secrets.
+	if certwatcher.Spec.Secret.Selector != nil {
+		certwatcher.Spec.Secret.Name = strings.TrimPrefix(certwatcher.Status.EmailDigest.Source, certwatcher.Spec.Secret.Namespace+"/")
+		certwatcher.Status.LastChecksum = certwatcher.Status.EmailDigest.Checksum
+	}
+	secret, err := r.getSource(ctx, certwatcher)
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: %s", err.Error())
+		certwatcher.Status.Message = "EMAIL: " + err.Error()
+		return r.updateCertWatcher(ctx, certwatcher, err)
+	}
+
+	err = renderFileReferences(certwatcher, secret)
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: %s", err.Error())
+		certwatcher.Status.Message = "EMAIL: " + err.Error()
+		return r.updateCertWatcher(ctx, certwatcher, err)
+	}
+	filesOptions, err := r.certificateFilesOptions(ctx, certwatcher)
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: %s", err.Error())
+		certwatcher.Status.Message = "EMAIL: " + err.Error()
+		return r.updateCertWatcher(ctx, certwatcher, err)
+	}
+	var withAttachments = true
+	if certwatcher.Spec.RandomPassword != nil {
+		password, err := r.readPasswordSecret(ctx, certwatcher)
+		if err != nil {
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: %s", err.Error())
+			certwatcher.Status.Message = "EMAIL: " + err.Error()
+			return r.updateCertWatcher(ctx, certwatcher, err)
+		}
+		if password == "" {
+			withAttachments = false
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: Random password no longer kept in a Secret, queued again without attachments")
+		}
+		filesOptions.Zip.Password = password
+		filesOptions.Pkcs12.Password = password
+	}
+	var certFilesDir string
+	if withAttachments {
+		certFilesDir, err = util.CreateCertificateFiles(secret, filesOptions)
+		defer func() {
+			err := os.RemoveAll(certFilesDir)
+			if err != nil {
+				log.Error(err, "Error removing temporary workspace directory: "+certFilesDir)
+			}
+		}()
+		if err != nil {
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: %s", err.Error())
+			certwatcher.Status.Message = "EMAIL: " + err.Error()
+			return r.updateCertWatcher(ctx, certwatcher, err)
+		}
+	}
+
+	var emailConfig = r.emailConfiguration(certwatcher.Spec.Actions.Email)
+	resources, err := r.getEmailResources(ctx, certwatcher.Spec.Actions.Email, emailConfig)
+	if err == nil {
+		err = r.queueEmailDigest(certwatcher, certFilesDir, emailConfig, resources)
+	}
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: %s", err.Error())
+		certwatcher.Status.Message = "EMAIL: " + err.Error()
+		return r.updateCertWatcher(ctx, certwatcher, err)
+	}
+	r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "EMAIL: Queued again for digest to %s", certwatcher.Spec.Actions.Email.To)
+	return r.updateCertWatcher(ctx, certwatcher, nil)
+}
+
+// emailDigestDelivered records the result of a digest e-mail in the status of
+// every CertWatcher included in it. CertWatchers that queued a newer change in
+// the meantime are left untouched. Failed notifications are retried up to
+// emailDigestMaxAttempts times, after which they are left Failed.
+func (r *CertWatcherReconciler) emailDigestDelivered(entries []util.EmailDigestEntry, deliveryErr error) {
+	ctx := context.Background()
+	for _, entry := range entries {
+		var certwatcher certwatchv1.CertWatcher
+		var updated bool
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if err := r.Get(ctx, entry.CertWatcher, &certwatcher); err != nil {
+				return err
+			}
+			var digest = certwatcher.Status.EmailDigest
+			if digest == nil || digest.Checksum != entry.Checksum {
+				updated = false
+				return nil
+			}
+			if deliveryErr != nil {
+				failedAt := apimachineryv1.NewTime(time.Now())
+				digest.State = util.EmailDigestRetrying
+				digest.Message = deliveryErr.Error()
+				digest.Attempts++
+				digest.FailedAt = &failedAt
+				if digest.Attempts >= emailDigestMaxAttempts {
+					digest.State = util.EmailDigestFailed
+				}
+			} else {
+				sentAt := apimachineryv1.NewTime(time.Now())
+				digest.State = util.EmailDigestSent
+				digest.SentAt = &sentAt
+				digest.Message = ""
+			}
+			certwatcher.Status.LastUpdate = apimachineryv1.Now()
+			updated = true
+			return r.Status().Update(ctx, &certwatcher)
+		})
+		if err != nil {
+			log.Error(err, entry.CertWatcher.String()+" Unable to update e-mail digest status")
+			continue
+		}
+		if !updated {
+			continue
+		}
+		if deliveryErr != nil && certwatcher.Status.EmailDigest.State == util.EmailDigestFailed {
+			r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: Digest failed %d times, giving up: %s", certwatcher.Status.EmailDigest.Attempts, deliveryErr.Error())
+		} else if deliveryErr != nil {
+			r.EventRecorder.Eventf(&certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: Digest failed, will be retried: %s", deliveryErr.Error())
+		} else {
+			r.EventRecorder.Eventf(&certwatcher, "Normal", "CertWatcherProcessing", "EMAIL: Digest sent")
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package certwatch
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	apicorev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
+)
+
+var defaultEndpointInterval = time.Hour
+var defaultEndpointExpiryThreshold = 14 * 24 * time.Hour
+
+// endpointOptions returns the options to connect to the remote endpoint
+// watched by the CertWatcher.
+func endpointOptions(certwatcher *certwatchv1.CertWatcher) util.EndpointOptions {
+	var spec = certwatcher.Spec.Endpoint
+	return util.EndpointOptions{
+		Host:       spec.Host,
+		Port:       spec.Port,
+		ServerName: spec.ServerName,
+		StartTLS:   spec.StartTLS,
+		Timeout:    spec.Timeout.Duration,
+	}
+}
+
+func endpointInterval(certwatcher *certwatchv1.CertWatcher) time.Duration {
+	if certwatcher.Spec.Endpoint.Interval.Duration > 0 {
+		return certwatcher.Spec.Endpoint.Interval.Duration
+	}
+	return defaultEndpointInterval
+}
+
+func endpointExpiryThreshold(certwatcher *certwatchv1.CertWatcher) time.Duration {
+	if certwatcher.Spec.Endpoint.ExpiryThreshold.Duration > 0 {
+		return certwatcher.Spec.Endpoint.ExpiryThreshold.Duration
+	}
+	return defaultEndpointExpiryThreshold
+}
+
+// getEndpointSecret performs a TLS handshake with the remote endpoint watched
+// by the CertWatcher and returns the certificates it presents as a Secret,
+// along with the leaf certificate. The result is recorded in the
+// EndpointReachable condition.
+func (r *CertWatcherReconciler) getEndpointSecret(certwatcher *certwatchv1.CertWatcher) (*apicorev1.Secret, *x509.Certificate, error) {
+	var options = endpointOptions(certwatcher)
+	var condition = apimachineryv1.Condition{
+		Type:               certwatchv1.CertWatcherConditionEndpointReachable,
+		Status:             apimachineryv1.ConditionTrue,
+		Reason:             "HandshakeSucceeded",
+		Message:            "TLS handshake with " + options.Address() + " succeeded",
+		ObservedGeneration: certwatcher.Generation,
+	}
+	certificates, err := util.GetEndpointCertificates(options)
+	if err != nil {
+		condition.Status = apimachineryv1.ConditionFalse
+		condition.Reason = "HandshakeFailed"
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&certwatcher.Status.Conditions, condition)
+	if err != nil {
+		return nil, nil, err
+	}
+	return util.EndpointAsSecret(options.Address(), certwatcher.Namespace, certificates), certificates[0], nil
+}
+
+// checkEndpointSecret checks the remote endpoint watched by the CertWatcher,
+// recording the result in its status, and returns the checksum of the
+// certificates presented.
+func (r *CertWatcherReconciler) checkEndpointSecret(certwatcher *certwatchv1.CertWatcher) (string, error) {
+	if certwatcher.Status.Endpoint == nil {
+		certwatcher.Status.Endpoint = &certwatchv1.CertWatcherEndpointStatus{}
+	}
+	certwatcher.Status.Endpoint.LastCheck = apimachineryv1.Now()
+	secret, leaf, err := r.getEndpointSecret(certwatcher)
+	if err != nil {
+		return "", err
+	}
+	var notAfter = apimachineryv1.NewTime(leaf.NotAfter)
+	certwatcher.Status.Endpoint.NotAfter = &notAfter
+	checksum, err := util.SecretDataChecksum(secret)
+	if err != nil {
+		return "", fmt.Errorf("unable to calculate checksum of certificates from %s: %s", sourceLogName(certwatcher), err.Error())
+	}
+	return checksum, nil
+}
+
+// endpointExpiring checks whether the leaf certificate presented by the remote
+// endpoint expires within the expiry threshold of the CertWatcher.
+func endpointExpiring(certwatcher *certwatchv1.CertWatcher) bool {
+	var status = certwatcher.Status.Endpoint
+	return status != nil && status.NotAfter != nil && time.Until(status.NotAfter.Time) < endpointExpiryThreshold(certwatcher)
+}
+
+// initEndpoint initializes a CertWatcher watching a remote endpoint, recording
+// the checksum of the certificates it presents. Actions are performed right
+// away if the leaf certificate is already about to expire.
+func (r *CertWatcherReconciler) initEndpoint(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (ctrl.Result, error) {
+	checksum, err := r.checkEndpointSecret(certwatcher)
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherInit", "%s", err.Error())
+		certwatcher.Status.Message = err.Error()
+		return r.updateCertWatcher(ctx, certwatcher, err)
+	}
+	certwatcher.Status.LastChecksum = checksum
+	certwatcher.Status.Endpoint.ExpiryNotified = false
+	certwatcher.Status.Status = "Ready"
+	certwatcher.Status.Message = "CertWatcher successfully initialized"
+	certwatcher.Status.ActionStatus = ""
+	r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherInit", "CertWatcher successfully initialized")
+	if endpointExpiring(certwatcher) {
+		r.setEndpointExpiring(certwatcher)
+	}
+	return r.updateCertWatcher(ctx, certwatcher, nil)
+}
+
+// setEndpointExpiring sets actions Pending for the approaching expiry of the
+// certificate presented by the remote endpoint, once per certificate chain.
+func (r *CertWatcherReconciler) setEndpointExpiring(certwatcher *certwatchv1.CertWatcher) {
+	var notAfter = certwatcher.Status.Endpoint.NotAfter.UTC().Format(time.RFC3339)
+	certwatcher.Status.Endpoint.ExpiryNotified = true
+	certwatcher.Status.ActionStatus = "Pending"
+	certwatcher.Status.PendingActions = nil
+	certwatcher.Status.Message = "Endpoint certificate expires at " + notAfter
+	r.EventRecorder.Eventf(certwatcher, "Warning", "EndpointExpiring", "Certificate presented by %s expires at %s", sourceLogName(certwatcher), notAfter)
+}
+
+// reconcileEndpoint checks the remote endpoint watched by a Ready CertWatcher
+// once its interval has elapsed since the last check, setting actions Pending
+// when the certificates presented change or are about to expire. The
+// CertWatcher is always requeued for its next check.
+func (r *CertWatcherReconciler) reconcileEndpoint(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (ctrl.Result, error) {
+	var interval = endpointInterval(certwatcher)
+	if status := certwatcher.Status.Endpoint; status != nil {
+		if wait := interval - time.Since(status.LastCheck.Time); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	checksum, err := r.checkEndpointSecret(certwatcher)
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "EndpointChecked", "%s", err.Error())
+		certwatcher.Status.Message = err.Error()
+	} else if certwatcher.Status.LastChecksum != checksum {
+		certwatcher.Status.LastChecksum = checksum
+		certwatcher.Status.Endpoint.ExpiryNotified = false
+		certwatcher.Status.ActionStatus = "Pending"
+		certwatcher.Status.PendingActions = nil
+		certwatcher.Status.Message = "Checksum updated"
+		r.EventRecorder.Eventf(certwatcher, "Normal", "EndpointChecked", "Certificates presented by %s changed, updating CertWatcher status.", sourceLogName(certwatcher))
+	} else if endpointExpiring(certwatcher) && !certwatcher.Status.Endpoint.ExpiryNotified {
+		r.setEndpointExpiring(certwatcher)
+	} else {
+		certwatcher.Status.Message = "Waiting for next endpoint change"
+	}
+
+	result, err := r.updateCertWatcher(ctx, certwatcher, nil)
+	if err == nil {
+		result.RequeueAfter = interval
+	}
+	return result, err
+}
@@ -0,0 +1,182 @@
+package certwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apicorev1 "k8s.io/api/core/v1"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
+)
+
+// validateRandomPassword checks that the random password of the CertWatcher
+// has at least one delivery channel, and that it is not e-mailed to anyone
+// who also receives the files.
+func validateRandomPassword(certwatcher *certwatchv1.CertWatcher) error {
+	var spec = certwatcher.Spec.RandomPassword
+	if spec.Email == nil && spec.Webhook == nil && spec.Secret == nil {
+		return errors.New("randomPassword requires at least one of email, webhook or secret to deliver the password")
+	}
+	if spec.Email != nil && certwatcher.Spec.Actions.Email != nil {
+		var action = certwatcher.Spec.Actions.Email
+		var recipients = map[string]bool{}
+		for _, list := range []string{action.To, action.Cc, action.Bcc} {
+			for _, address := range strings.Split(list, ",") {
+				recipients[strings.ToLower(strings.TrimSpace(address))] = true
+			}
+		}
+		for _, address := range strings.Split(spec.Email.To, ",") {
+			address = strings.ToLower(strings.TrimSpace(address))
+			if address != "" && recipients[address] {
+				return fmt.Errorf("randomPassword e-mail recipient %s also receives the certificate files", address)
+			}
+		}
+	}
+	if spec.Webhook != nil && spec.Webhook.URL == "" {
+		return errors.New("randomPassword webhook requires an url")
+	}
+	if spec.Secret != nil && spec.Secret.Name == "" {
+		return errors.New("randomPassword secret requires a name")
+	}
+	if spec.Secret != nil && spec.Secret.Name == certwatcher.Spec.Secret.Name && certwatcher.Spec.Secret.Namespace == certwatcher.Namespace {
+		return fmt.Errorf("randomPassword secret %s is the watched Secret", spec.Secret.Name)
+	}
+	return nil
+}
+
+// deliverRandomPassword sends the random password through the e-mail and
+// webhook channels configured in the CertWatcher. The secret channel is the
+// password Secret itself, written by randomPassword. Files protected with it
+// should only be sent after it has been delivered.
+func (r *CertWatcherReconciler) deliverRandomPassword(ctx context.Context, certwatcher *certwatchv1.CertWatcher, password string) error {
+	var err error
+	var spec = certwatcher.Spec.RandomPassword
+	var notification = util.PasswordNotification{
+		CertWatcher: certwatcher.Namespace + "/" + certwatcher.Name,
+		Secret:      sourceLogName(certwatcher),
+		Checksum:    certwatcher.Status.LastChecksum,
+		Password:    password,
+	}
+
+	if spec.Webhook != nil {
+		var headers map[string][]byte
+		if spec.Webhook.HeadersSecret != "" {
+			name, err := parseNamespacedName(spec.Webhook.HeadersSecret)
+			if err != nil {
+				return fmt.Errorf("PASSWORD: webhook headers: %s", err.Error())
+			}
+			var secret apicorev1.Secret
+			if err = r.Get(ctx, name, &secret); err != nil {
+				return fmt.Errorf("PASSWORD: webhook headers: %s", err.Error())
+			}
+			headers = secret.Data
+		}
+		if err = util.ProcessPasswordWebhook(ctx, spec.Webhook, headers, notification); err != nil {
+			return fmt.Errorf("PASSWORD: %s", err.Error())
+		}
+	}
+
+	if spec.Email != nil {
+		var emailConfig = r.EmailConfiguration
+		if spec.Email.ConfigFile != "" {
+			emailConfig = r.emailConfiguration(&certwatchv1.CertWatchActionEmail{ConfigFile: spec.Email.ConfigFile})
+		}
+		resources, err := r.getEmailResources(ctx, &certwatchv1.CertWatchActionEmail{}, emailConfig)
+		if err == nil {
+			err = util.ProcessPasswordEmail(ctx, r.MailSender, spec.Email, emailConfig, resources, notification)
+		}
+		if err != nil {
+			return fmt.Errorf("PASSWORD: e-mail to %s: %s", spec.Email.To, err.Error())
+		}
+	}
+	return nil
+}
+
+// randomPasswordChecksumAnnotation records in the password Secret the
+// checksum of the change its password was generated for.
+const randomPasswordChecksumAnnotation = "certwatch.morimoto.net.br/checksum"
+
+// passwordSecret returns the name of the Secret keeping the random password of
+// the CertWatcher, and the key holding it. Without a secret channel, it is
+// kept in a Secret named after the CertWatcher.
+func passwordSecret(certwatcher *certwatchv1.CertWatcher) (string, string) {
+	var spec = certwatcher.Spec.RandomPassword.Secret
+	if spec == nil {
+		return certwatcher.Name + "-random-password", "password"
+	}
+	if spec.Key == "" {
+		return spec.Name, "password"
+	}
+	return spec.Name, spec.Key
+}
+
+// randomPassword returns the random password for the current checksum of the
+// CertWatcher. A password is generated once per checksum and written to the
+// password Secret before it is delivered, so retries of failed actions deliver
+// and use the same password.
+func (r *CertWatcherReconciler) randomPassword(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (string, error) {
+	password, err := r.readPasswordSecret(ctx, certwatcher)
+	if err != nil {
+		return "", fmt.Errorf("PASSWORD: %s", err.Error())
+	}
+	if password != "" {
+		return password, nil
+	}
+	password, err = util.GeneratePassword(certwatcher.Spec.RandomPassword.Length)
+	if err != nil {
+		return "", err
+	}
+	if err = r.writePasswordSecret(ctx, certwatcher, password); err != nil {
+		name, _ := passwordSecret(certwatcher)
+		return "", fmt.Errorf("PASSWORD: cannot write Secret %s/%s: %s", certwatcher.Namespace, name, err.Error())
+	}
+	return password, nil
+}
+
+// writePasswordSecret creates or updates the Secret holding the random
+// password, owned by the CertWatcher, along with the checksum it was generated
+// for. An existing Secret not controlled by the CertWatcher is never taken
+// over.
+func (r *CertWatcherReconciler) writePasswordSecret(ctx context.Context, certwatcher *certwatchv1.CertWatcher, password string) error {
+	name, key := passwordSecret(certwatcher)
+	var secret = &apicorev1.Secret{
+		ObjectMeta: apimachineryv1.ObjectMeta{Namespace: certwatcher.Namespace, Name: name},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		if !secret.CreationTimestamp.IsZero() && !apimachineryv1.IsControlledBy(secret, certwatcher) {
+			return errors.New("Secret exists and is not controlled by the CertWatcher")
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[key] = []byte(password)
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[randomPasswordChecksumAnnotation] = certwatcher.Status.LastChecksum
+		return controllerutil.SetControllerReference(certwatcher, secret, r.Scheme)
+	})
+	return err
+}
+
+// readPasswordSecret returns the random password kept in the Secret controlled
+// by the CertWatcher, or an empty string when it was not generated for the
+// current checksum yet.
+func (r *CertWatcherReconciler) readPasswordSecret(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (string, error) {
+	name, key := passwordSecret(certwatcher)
+	var secret apicorev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: certwatcher.Namespace, Name: name}, &secret); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	if !apimachineryv1.IsControlledBy(&secret, certwatcher) || secret.Annotations[randomPasswordChecksumAnnotation] != certwatcher.Status.LastChecksum {
+		return "", nil
+	}
+	return string(secret.Data[key]), nil
+}
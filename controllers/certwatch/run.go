@@ -0,0 +1,78 @@
+package certwatch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+)
+
+// requestRun sets the actions of the CertWatcher Pending when its run
+// annotation holds a new nonce, recording who requested the run. With a
+// selector, actions are performed for every Secret selected. Returns false
+// when no new run was requested.
+func (r *CertWatcherReconciler) requestRun(certwatcher *certwatchv1.CertWatcher) bool {
+	var nonce = certwatcher.Annotations[certwatchv1.CertWatcherRunAnnotation]
+	if nonce == "" || (certwatcher.Status.Run != nil && certwatcher.Status.Run.Nonce == nonce) {
+		return false
+	}
+	certwatcher.Status.Run = &certwatchv1.CertWatcherRunStatus{
+		Nonce:       nonce,
+		RequestedBy: runRequester(certwatcher),
+		RequestedAt: apimachineryv1.Now(),
+	}
+	certwatcher.Status.PendingActions = nil
+	setPending(certwatcher, "Run requested by "+certwatcher.Status.Run.RequestedBy)
+	r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherRun", "Run %s requested by %s", nonce, certwatcher.Status.Run.RequestedBy)
+	return true
+}
+
+// runRequester returns the field manager that last set the run annotation of
+// the CertWatcher, which names the client, not the user. Users can be told
+// apart with audit logs.
+func runRequester(certwatcher *certwatchv1.CertWatcher) string {
+	var field = []byte(fmt.Sprintf(`"f:%s"`, certwatchv1.CertWatcherRunAnnotation))
+	var requester = "unknown"
+	var latest apimachineryv1.Time
+	for _, entry := range certwatcher.ManagedFields {
+		if entry.FieldsV1 == nil || !bytes.Contains(entry.FieldsV1.Raw, field) {
+			continue
+		}
+		if entry.Time == nil || !entry.Time.Before(&latest) {
+			requester = entry.Manager
+			if entry.Time != nil {
+				latest = *entry.Time
+			}
+		}
+	}
+	return requester
+}
+
+// completeRun records that the actions of a run requested with the run
+// annotation were performed.
+func completeRun(certwatcher *certwatchv1.CertWatcher) {
+	if certwatcher.Status.Run != nil && certwatcher.Status.Run.CompletedAt == nil {
+		var now = apimachineryv1.Now()
+		certwatcher.Status.Run.CompletedAt = &now
+	}
+}
+
+// clearRunAnnotation removes the run annotation of the CertWatcher once its
+// run is completed. Returns whether the annotation had to be removed.
+func (r *CertWatcherReconciler) clearRunAnnotation(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (bool, error) {
+	var run = certwatcher.Status.Run
+	nonce, ok := certwatcher.Annotations[certwatchv1.CertWatcherRunAnnotation]
+	if !ok || run == nil || run.Nonce != nonce || run.CompletedAt == nil {
+		return false, nil
+	}
+	var patch = client.MergeFrom(certwatcher.DeepCopy())
+	delete(certwatcher.Annotations, certwatchv1.CertWatcherRunAnnotation)
+	if err := r.Patch(ctx, certwatcher, patch); err != nil {
+		return true, fmt.Errorf("unable to remove run annotation: %s", err.Error())
+	}
+	return true, nil
+}
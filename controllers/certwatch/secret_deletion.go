@@ -0,0 +1,43 @@
+package certwatch
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
+)
+
+// processOnDelete performs the onDelete actions of a CertWatcher whose Secret
+// was deleted. E-mails are sent right away, without attachments, even if the
+// e-mail action is in digest mode.
+func (r *CertWatcherReconciler) processOnDelete(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (ctrl.Result, error) {
+	var spec = certwatcher.Spec.Secret.OnDelete
+	var secretlogname = sourceLogName(certwatcher)
+	r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "Processing onDelete actions")
+	if spec != nil && spec.Echo != nil {
+		r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "ECHO: Good night to %s", secretlogname)
+	}
+	if spec != nil && spec.Email != nil {
+		var target = certwatcher.DeepCopy()
+		target.Spec.Actions.Email = spec.Email.DeepCopy()
+		target.Spec.Actions.Email.Attachments = nil
+		target.Spec.Actions.Email.Digest = nil
+		var emailConfig = r.emailConfiguration(target.Spec.Actions.Email)
+		emailResources, err := r.getEmailResources(ctx, target.Spec.Actions.Email, emailConfig)
+		if err == nil {
+			r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "EMAIL: Sending mail to %s via %s:%d", target.Spec.Actions.Email.To, emailConfig.GetString("host", ""), emailConfig.GetInt("port", 0))
+			err = util.ProcessEmail(ctx, r.MailSender, target, "", emailConfig, emailResources)
+		}
+		if err != nil {
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherProcessing", "EMAIL: %s", err.Error())
+			certwatcher.Status.Message = "EMAIL: " + err.Error()
+			return r.updateCertWatcher(ctx, certwatcher, err)
+		}
+	}
+	certwatcher.Status.SecretDeleted.ActionStatus = "Ready"
+	certwatcher.Status.Message = "Secret " + secretlogname + " deleted, waiting for it to be created again"
+	r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "onDelete action processing finished successfully")
+	return r.updateCertWatcher(ctx, certwatcher, nil)
+}
@@ -0,0 +1,113 @@
+package certwatch
+
+import (
+	"context"
+	"fmt"
+
+	apicorev1 "k8s.io/api/core/v1"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
+)
+
+// selectedSecrets lists the Secrets matched by the selector of the
+// CertWatcher, of the types it supports.
+func (r *CertWatcherReconciler) selectedSecrets(ctx context.Context, certwatcher *certwatchv1.CertWatcher) ([]apicorev1.Secret, error) {
+	selector, err := apimachineryv1.LabelSelectorAsSelector(certwatcher.Spec.Secret.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret selector: %s", err.Error())
+	}
+	var secretList apicorev1.SecretList
+	err = r.List(ctx, &secretList, client.InNamespace(certwatcher.Spec.Secret.Namespace), client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+	var secrets []apicorev1.Secret
+	for _, secret := range secretList.Items {
+		if util.SecretTypeSupported(&secret, certwatcher.Spec.Secret.Keys) {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets, nil
+}
+
+// initSelectedSecrets initializes a CertWatcher with a selector, recording
+// the checksum of every Secret currently matched. Like a CertWatcher watching
+// a single Secret, no actions are performed for them until they change.
+func (r *CertWatcherReconciler) initSelectedSecrets(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (ctrl.Result, error) {
+	var selectorlogname = certwatcher.Spec.Secret.Namespace + "/" + apimachineryv1.FormatLabelSelector(certwatcher.Spec.Secret.Selector)
+	secrets, err := r.selectedSecrets(ctx, certwatcher)
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherInit", "Unable to list Secrets %s: %s", selectorlogname, err.Error())
+		certwatcher.Status.Message = "Unable to list Secrets " + selectorlogname + ": " + err.Error()
+		return r.updateCertWatcher(ctx, certwatcher, err)
+	}
+	var now = apimachineryv1.Now()
+	certwatcher.Status.Secrets = nil
+	for _, secret := range secrets {
+		checksum, err := util.SecretChecksum(&secret, certwatcher.Spec.Secret.Keys, certwatcher.Spec.Checksum)
+		if err != nil {
+			r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherInit", "calculate secret checksum %s/%s: %s", secret.Namespace, secret.Name, err.Error())
+			certwatcher.Status.Message = "Unable to calculate Secret checksum " + secret.Namespace + "/" + secret.Name + ": " + err.Error()
+			return r.updateCertWatcher(ctx, certwatcher, err)
+		}
+		certwatcher.Status.Secrets = append(certwatcher.Status.Secrets, certwatchv1.CertWatcherSecretStatus{
+			Name:       secret.Name,
+			Checksum:   checksum,
+			Message:    "Secret selected",
+			LastUpdate: now,
+		})
+	}
+	certwatcher.Status.Status = "Ready"
+	certwatcher.Status.Message = fmt.Sprintf("CertWatcher successfully initialized with %d Secret(s)", len(secrets))
+	certwatcher.Status.ActionStatus = ""
+	r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherInit", "CertWatcher successfully initialized with %d Secret(s)", len(secrets))
+	return r.updateCertWatcher(ctx, certwatcher, nil)
+}
+
+// processSelectedSecrets performs the actions of a CertWatcher with a
+// selector for every Secret with pending actions. Each Secret is processed
+// on its own copy of the CertWatcher, so templates are rendered for each
+// one. Secrets whose actions fail stay Pending and are retried along with the
+// CertWatcher.
+func (r *CertWatcherReconciler) processSelectedSecrets(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (ctrl.Result, error) {
+	var failed int
+	var lastErr error
+	for i := range certwatcher.Status.Secrets {
+		var entry = &certwatcher.Status.Secrets[i]
+		if entry.ActionStatus != "Pending" {
+			continue
+		}
+		var target = certwatcher.DeepCopy()
+		target.Spec.Secret.Name = entry.Name
+		target.Status.LastChecksum = entry.Checksum
+		err := r.processActions(ctx, target)
+
+		certwatcher.Status.Conditions = target.Status.Conditions
+		certwatcher.Status.EmailDigest = target.Status.EmailDigest
+		entry.LastUpdate = apimachineryv1.Now()
+		if err != nil {
+			failed++
+			lastErr = err
+			entry.Message = target.Status.Message
+			continue
+		}
+		entry.ActionStatus = "Ready"
+		entry.Message = "Action processing finished successfully"
+		r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "Action processing finished successfully for %s/%s", certwatcher.Spec.Secret.Namespace, entry.Name)
+	}
+
+	if lastErr != nil {
+		certwatcher.Status.Message = fmt.Sprintf("Action processing failed for %d Secret(s): %s", failed, lastErr.Error())
+		return r.updateCertWatcher(ctx, certwatcher, lastErr)
+	}
+	certwatcher.Status.ActionStatus = "Ready"
+	certwatcher.Status.Message = "Waiting for next Secret change"
+	certwatcher.Status.PendingActions = nil
+	completeRun(certwatcher)
+	r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherProcessing", "Action processing finished successfully")
+	return r.updateCertWatcher(ctx, certwatcher, nil)
+}
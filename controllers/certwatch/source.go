@@ -0,0 +1,124 @@
+package certwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apicorev1 "k8s.io/api/core/v1"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
+)
+
+// validateSource checks that the CertWatcher watches exactly one of a Secret,
+// either by name or by selector, a ConfigMap, a cert-manager Certificate or a
+// remote endpoint.
+func validateSource(certwatcher *certwatchv1.CertWatcher) error {
+	var sources int
+	if certwatcher.Spec.Secret.Name != "" || certwatcher.Spec.Secret.Selector != nil {
+		sources++
+	}
+	if certwatcher.Spec.ConfigMap != nil {
+		sources++
+	}
+	if certwatcher.Spec.Certificate != nil {
+		sources++
+	}
+	if certwatcher.Spec.Endpoint != nil {
+		sources++
+	}
+	if sources == 0 {
+		return errors.New("one of secret, configMap, certificate or endpoint is required")
+	}
+	if sources > 1 {
+		return errors.New("only one of secret, configMap, certificate or endpoint can be used")
+	}
+	if certwatcher.Spec.Endpoint != nil && certwatcher.Spec.Endpoint.Host == "" {
+		return errors.New("endpoint host is required")
+	}
+	if certwatcher.Spec.Secret.Name != "" && certwatcher.Spec.Secret.Selector != nil {
+		return errors.New("secret name and selector cannot be used together")
+	}
+	return nil
+}
+
+// sourceLogName returns namespace/name of the Secret, ConfigMap or
+// Certificate watched by the CertWatcher, or host:port of its endpoint.
+func sourceLogName(certwatcher *certwatchv1.CertWatcher) string {
+	if certwatcher.Spec.Endpoint != nil {
+		return endpointOptions(certwatcher).Address()
+	}
+	if certwatcher.Spec.Certificate != nil {
+		return certwatcher.Spec.Certificate.Namespace + "/" + certwatcher.Spec.Certificate.Name
+	}
+	if certwatcher.Spec.ConfigMap != nil {
+		return certwatcher.Spec.ConfigMap.Namespace + "/" + certwatcher.Spec.ConfigMap.Name
+	}
+	return certwatcher.Spec.Secret.Namespace + "/" + certwatcher.Spec.Secret.Name
+}
+
+// getSource reads the contents watched by the CertWatcher as a Secret with the
+// keys of kubernetes.io/tls Secrets. A ConfigMap is returned as a Secret
+// holding its certificates in tls.crt alone, and so are the certificates
+// presented by an endpoint. For a Certificate, its Secret is
+// set as the Secret of the CertWatcher, in memory only, so actions referring
+// to the Secret find it.
+func (r *CertWatcherReconciler) getSource(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (*apicorev1.Secret, error) {
+	if certwatcher.Spec.Endpoint != nil {
+		secret, _, err := r.getEndpointSecret(certwatcher)
+		return secret, err
+	}
+	if spec := certwatcher.Spec.Certificate; spec != nil {
+		state, err := r.getCertificateState(ctx, certwatcher)
+		if err != nil {
+			return nil, err
+		}
+		if state.Ready != apimachineryv1.ConditionTrue {
+			return nil, fmt.Errorf("certificate %s is not Ready: %s", sourceLogName(certwatcher), state.Message)
+		}
+		certwatcher.Spec.Secret = certwatchv1.CertWatcherSecret{Name: state.SecretName, Namespace: spec.Namespace}
+	}
+	if spec := certwatcher.Spec.ConfigMap; spec != nil {
+		var cm apicorev1.ConfigMap
+		if err := r.Get(ctx, types.NamespacedName{Namespace: spec.Namespace, Name: spec.Name}, &cm); err != nil {
+			return nil, fmt.Errorf("unable to find ConfigMap for processing %s: %s", sourceLogName(certwatcher), err.Error())
+		}
+		return util.ConfigMapAsSecret(&cm, spec.Key)
+	}
+	var secret apicorev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: certwatcher.Spec.Secret.Namespace, Name: certwatcher.Spec.Secret.Name}, &secret); err != nil {
+		return nil, fmt.Errorf("unable to find Secret for processing %s/%s: %s", certwatcher.Spec.Secret.Namespace, certwatcher.Spec.Secret.Name, err.Error())
+	}
+	return util.NormalizeSecretKeys(&secret, certwatcher.Spec.Secret.Keys)
+}
+
+// sourceChecksum calculates the checksum of the Secret or ConfigMap watched by
+// the CertWatcher, as their reconcilers do.
+func (r *CertWatcherReconciler) sourceChecksum(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (string, error) {
+	if spec := certwatcher.Spec.ConfigMap; spec != nil {
+		var cm apicorev1.ConfigMap
+		if err := r.Get(ctx, types.NamespacedName{Namespace: spec.Namespace, Name: spec.Name}, &cm); err != nil {
+			return "", fmt.Errorf("unable to find ConfigMap %s: %s", sourceLogName(certwatcher), err.Error())
+		}
+		checksum, err := util.ConfigMapChecksum(&cm, spec.Key, certwatcher.Spec.Checksum)
+		if err != nil {
+			return "", fmt.Errorf("unable to calculate ConfigMap checksum %s: %s", sourceLogName(certwatcher), err.Error())
+		}
+		return checksum, nil
+	}
+	var secret apicorev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: certwatcher.Spec.Secret.Namespace, Name: certwatcher.Spec.Secret.Name}, &secret); err != nil {
+		return "", fmt.Errorf("unable to find Secret %s: %s", sourceLogName(certwatcher), err.Error())
+	}
+	if !util.SecretTypeSupported(&secret, certwatcher.Spec.Secret.Keys) {
+		return "", fmt.Errorf("secret %s has type %s, only %s is supported unless keys are set", sourceLogName(certwatcher), secret.Type, apicorev1.SecretTypeTLS)
+	}
+	checksum, err := util.SecretChecksum(&secret, certwatcher.Spec.Secret.Keys, certwatcher.Spec.Checksum)
+	if err != nil {
+		return "", fmt.Errorf("unable to calculate Secret checksum %s: %s", sourceLogName(certwatcher), err.Error())
+	}
+	return checksum, nil
+}
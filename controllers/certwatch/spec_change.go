@@ -0,0 +1,177 @@
+package certwatch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	apicorev1 "k8s.io/api/core/v1"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
+)
+
+// observeSpec records the generation of the spec of the CertWatcher, along
+// with the checksums of its actions and checksum settings, as seen by the
+// controller.
+func observeSpec(certwatcher *certwatchv1.CertWatcher) error {
+	checksums, err := util.ActionChecksums(certwatcher.Spec.Actions)
+	if err != nil {
+		return err
+	}
+	settings, err := util.ChecksumSettings(certwatcher.Spec)
+	if err != nil {
+		return err
+	}
+	certwatcher.Status.ObservedGeneration = certwatcher.Generation
+	certwatcher.Status.ActionChecksums = checksums
+	certwatcher.Status.ChecksumSettings = settings
+	return nil
+}
+
+// specChanged applies the onSpecChange policy of a Ready CertWatcher whose
+// spec changed since it was last observed, setting all actions, or just the
+// ones added or changed, Pending. Nothing is performed for CertWatchers never
+// observed before, which were just initialized or created by an earlier
+// version.
+//
+// When the checksum settings changed, the checksum of the source is calculated
+// again and recorded without performing any actions, so the next unrelated
+// change of the source is not mistaken for a change of its contents.
+func (r *CertWatcherReconciler) specChanged(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (ctrl.Result, error) {
+	var observed = certwatcher.Status.ObservedGeneration
+	var previous = certwatcher.Status.ActionChecksums
+	settings, err := util.ChecksumSettings(certwatcher.Spec)
+	if err == nil && observed != 0 && certwatcher.Status.ChecksumSettings != "" && certwatcher.Status.ChecksumSettings != settings {
+		err = r.rebaselineChecksum(ctx, certwatcher)
+		if err == nil {
+			r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherSpecChanged", "Checksum settings changed, updating checksum only")
+		}
+	}
+	if err == nil {
+		err = observeSpec(certwatcher)
+	}
+	if err != nil {
+		r.EventRecorder.Eventf(certwatcher, "Warning", "CertWatcherSpecChanged", "%s", err.Error())
+		certwatcher.Status.Message = err.Error()
+		return r.updateCertWatcher(ctx, certwatcher, err)
+	}
+	if observed == 0 {
+		return r.updateCertWatcher(ctx, certwatcher, nil)
+	}
+
+	switch certwatcher.Spec.OnSpecChange {
+	case "RunAll":
+		certwatcher.Status.PendingActions = nil
+		setPending(certwatcher, "Spec changed")
+		r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherSpecChanged", "Spec changed, performing all actions")
+	case "RunNew":
+		var changed []string
+		for name, checksum := range certwatcher.Status.ActionChecksums {
+			if previous[name] != checksum {
+				changed = append(changed, name)
+			}
+		}
+		if len(changed) == 0 {
+			break
+		}
+		sort.Strings(changed)
+		certwatcher.Status.PendingActions = changed
+		setPending(certwatcher, "Spec changed")
+		r.EventRecorder.Eventf(certwatcher, "Normal", "CertWatcherSpecChanged", "Spec changed, performing new actions: %s", strings.Join(changed, ", "))
+	}
+	return r.updateCertWatcher(ctx, certwatcher, nil)
+}
+
+// rebaselineChecksum calculates the checksum of the source of the CertWatcher
+// again, with its current checksum settings, leaving actions untouched.
+// Checksums of remote endpoints do not depend on these settings, and deleted
+// Secrets have no checksum until created again.
+func (r *CertWatcherReconciler) rebaselineChecksum(ctx context.Context, certwatcher *certwatchv1.CertWatcher) error {
+	if certwatcher.Spec.Endpoint != nil || certwatcher.Status.SecretDeleted != nil {
+		return nil
+	}
+	if certwatcher.Spec.Secret.Selector != nil {
+		secrets, err := r.selectedSecrets(ctx, certwatcher)
+		if err != nil {
+			return err
+		}
+		for _, secret := range secrets {
+			for i := range certwatcher.Status.Secrets {
+				if certwatcher.Status.Secrets[i].Name != secret.Name {
+					continue
+				}
+				checksum, err := util.SecretChecksum(&secret, certwatcher.Spec.Secret.Keys, certwatcher.Spec.Checksum)
+				if err != nil {
+					return fmt.Errorf("unable to calculate Secret checksum %s/%s: %s", secret.Namespace, secret.Name, err.Error())
+				}
+				certwatcher.Status.Secrets[i].Checksum = checksum
+			}
+		}
+		return nil
+	}
+	if spec := certwatcher.Spec.Certificate; spec != nil {
+		if certwatcher.Status.Certificate == nil {
+			return nil
+		}
+		var secret apicorev1.Secret
+		if err := r.Get(ctx, types.NamespacedName{Namespace: spec.Namespace, Name: certwatcher.Status.Certificate.SecretName}, &secret); err != nil {
+			return fmt.Errorf("unable to find Secret %s/%s: %s", spec.Namespace, certwatcher.Status.Certificate.SecretName, err.Error())
+		}
+		checksum, err := util.SecretChecksum(&secret, certwatcher.Spec.Secret.Keys, certwatcher.Spec.Checksum)
+		if err != nil {
+			return fmt.Errorf("unable to calculate Secret checksum %s/%s: %s", spec.Namespace, secret.Name, err.Error())
+		}
+		certwatcher.Status.LastChecksum = checksum
+		return nil
+	}
+	checksum, err := r.sourceChecksum(ctx, certwatcher)
+	if err != nil {
+		return err
+	}
+	certwatcher.Status.LastChecksum = checksum
+	return nil
+}
+
+// limitActions removes, in memory only, the actions of the CertWatcher not
+// listed in its PendingActions, if any.
+func limitActions(certwatcher *certwatchv1.CertWatcher) {
+	if len(certwatcher.Status.PendingActions) == 0 {
+		return
+	}
+	var pending = map[string]bool{}
+	for _, name := range certwatcher.Status.PendingActions {
+		pending[name] = true
+	}
+	var actions = &certwatcher.Spec.Actions
+	if !pending["echo"] {
+		actions.Echo = nil
+	}
+	if !pending["email"] {
+		actions.Email = nil
+	}
+	if !pending["scp"] {
+		actions.Scp = nil
+	}
+	if !pending["job"] {
+		actions.Job = nil
+	}
+}
+
+// setPending sets the actions of the CertWatcher Pending for its current
+// Secret contents. With a selector, actions are performed for every Secret
+// selected.
+func setPending(certwatcher *certwatchv1.CertWatcher, message string) {
+	var now = apimachineryv1.Now()
+	for i := range certwatcher.Status.Secrets {
+		certwatcher.Status.Secrets[i].ActionStatus = "Pending"
+		certwatcher.Status.Secrets[i].Message = message
+		certwatcher.Status.Secrets[i].LastUpdate = now
+	}
+	certwatcher.Status.ActionStatus = "Pending"
+	certwatcher.Status.Message = message
+}
@@ -0,0 +1,62 @@
+package certwatch
+
+import (
+	"context"
+
+	apicorev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
+)
+
+// validateCertificate validates the Secret contents according to the
+// CertWatcher and records the result in its CertificateValid condition.
+// Certificates from a ConfigMap or an endpoint, without private key, are only
+// checked to be valid PEM.
+func (r *CertWatcherReconciler) validateCertificate(ctx context.Context, certwatcher *certwatchv1.CertWatcher, secret *apicorev1.Secret) error {
+	var options util.ValidationOptions
+	var err error
+	if spec := certwatcher.Spec.Validation; spec != nil {
+		options.VerifyChain = spec.VerifyChain
+		options.MinRSAKeySize = spec.MinRSAKeySize
+		options.MinECKeySize = spec.MinECKeySize
+		options.SignatureAlgorithms = spec.SignatureAlgorithms
+		if spec.CABundleSecretKeyRef != nil {
+			var bundle string
+			bundle, err = r.getSecretValue(ctx, spec.CABundleSecretKeyRef)
+			if err == nil {
+				options.CABundle, err = util.ParseCertificates([]byte(bundle))
+			}
+			if err != nil {
+				err = &util.ValidationError{Reason: util.ValidationReasonInvalidCABundle, Message: "CA bundle: " + err.Error()}
+			}
+		}
+	}
+	if err == nil {
+		if certwatcher.Spec.ConfigMap != nil || certwatcher.Spec.Endpoint != nil {
+			err = util.ValidateCertificateBundle(secret)
+		} else {
+			err = util.ValidateCertificate(secret, options)
+		}
+	}
+
+	var condition = apimachineryv1.Condition{
+		Type:               certwatchv1.CertWatcherConditionCertificateValid,
+		Status:             apimachineryv1.ConditionTrue,
+		Reason:             util.ValidationReasonValid,
+		Message:            "Certificate passed validation",
+		ObservedGeneration: certwatcher.Generation,
+	}
+	if err != nil {
+		condition.Status = apimachineryv1.ConditionFalse
+		condition.Reason = util.ValidationReasonInvalidCertificate
+		condition.Message = err.Error()
+		if validationErr, ok := err.(*util.ValidationError); ok {
+			condition.Reason = validationErr.Reason
+		}
+	}
+	meta.SetStatusCondition(&certwatcher.Status.Conditions, condition)
+	return err
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+)
+
+var configmaplog = ctrl.Log.WithName("ConfigMapController")
+
+// ConfigMapReconciler reconciles a ConfigMap object, for CertWatchers watching
+// certificates without private key, such as CA bundles.
+type ConfigMapReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+
+func (r *ConfigMapReconciler) updateCertWatcher(ctx context.Context, certwatcher *certwatchv1.CertWatcher) (ctrl.Result, error) {
+	certwatcher.Status.LastUpdate = apimachineryv1.Now()
+	if err := r.Status().Update(ctx, certwatcher); err != nil {
+		configmaplog.Error(err, certwatcher.Namespace+"/"+certwatcher.Name+" Unable to update CertWatcher")
+		return ctrl.Result{Requeue: true}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *ConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var configmaplogname string = req.Namespace + "/" + req.Name
+	var cm corev1.ConfigMap
+	err := r.Get(ctx, req.NamespacedName, &cm)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			configmaplog.Info(configmaplogname + " Unable to get ConfigMap: " + err.Error())
+		} else {
+			configmaplog.Error(err, configmaplogname+" Unable to get ConfigMap")
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(nil)
+	}
+
+	// Most ConfigMaps are not watched by any CertWatcher, so look for them
+	// before calculating checksums.
+	var cwList certwatchv1.CertWatcherList
+	err = r.List(ctx, &cwList, client.MatchingFields{".spec.configMap.name": cm.Name}, client.InNamespace(cm.Namespace))
+	if err != nil {
+		configmaplog.Error(err, configmaplogname+" Unable to get CertWatcher list")
+		return ctrl.Result{Requeue: true}, err
+	}
+	if len(cwList.Items) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	var updateErr error
+	for _, cw := range cwList.Items {
+		if cw.Spec.ConfigMap == nil || cw.Spec.ConfigMap.Namespace != cm.Namespace {
+			continue
+		}
+		dataChecksum, err := util.ConfigMapChecksum(&cm, cw.Spec.ConfigMap.Key, cw.Spec.Checksum)
+		if err != nil {
+			configmaplog.Error(err, configmaplogname+" Unable to calculate checksum for CertWatcher "+cw.Namespace+"/"+cw.Name)
+			r.EventRecorder.Eventf(&cw, "Warning", "ConfigMapChanged", "Unable to calculate ConfigMap checksum: %s", err.Error())
+			continue
+		}
+		if cw.Status.Status != "Ready" {
+			r.EventRecorder.Eventf(&cw, "Warning", "ConfigMapChanged", "ConfigMap changed, but CertWatcher not Ready.")
+		}
+		if cw.Status.ActionStatus == "Pending" {
+			r.EventRecorder.Eventf(&cw, "Warning", "ConfigMapChanged", "ConfigMap changed, but CertWatcher has Pending actions.")
+		}
+		if cw.Status.LastChecksum != dataChecksum {
+			cw.Status.LastChecksum = dataChecksum
+			cw.Status.Message = "Checksum updated"
+			cw.Status.ActionStatus = "Pending"
+			cw.Status.PendingActions = nil
+			r.EventRecorder.Eventf(&cw, "Normal", "ConfigMapChanged", "Updating CertWatcher status.")
+			if _, err = r.updateCertWatcher(ctx, &cw); err != nil {
+				updateErr = err
+			}
+		}
+	}
+	// Updates lost to conflicts with a stale cache are retried with the
+	// ConfigMap.
+	if updateErr != nil {
+		return ctrl.Result{Requeue: true}, updateErr
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ConfigMapReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	var rateLimiter ratelimiter.RateLimiter = workqueue.NewItemFastSlowRateLimiter(retryFastDelay, retrySlowDelay, retryMaxFastAttempts)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.ConfigMap{}).
+		WithOptions(controller.Options{RateLimiter: rateLimiter}).
+		Complete(r)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
@@ -50,34 +51,50 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			log.Info(secretlogname + " Unable to get Secret: " + err.Error())
+			if err = r.secretDeleted(ctx, req.NamespacedName); err != nil {
+				return ctrl.Result{Requeue: true}, err
+			}
 		} else {
 			log.Error(err, secretlogname+" Unable to get Secret")
 		}
 		return ctrl.Result{}, client.IgnoreNotFound(nil)
 	}
-	if s.Type != "kubernetes.io/tls" {
+	// Opaque Secrets are only watched by CertWatchers declaring their keys,
+	// which is checked for each CertWatcher below.
+	if s.Type != corev1.SecretTypeTLS && s.Type != corev1.SecretTypeOpaque {
 		return ctrl.Result{}, client.IgnoreNotFound(nil)
 	}
-	dataChecksum, err := util.SecretDataChecksum(&s)
-	if err != nil {
-		log.Error(err, secretlogname+" Unable to serialize secret data")
-		// Wait a minute before trying again
-		return ctrl.Result{Requeue: true}, err
-	}
-
 	// Find CertWatchers that watch this particular Secret and update their statuses
 	var cwList certwatchv1.CertWatcherList
 	err = r.List(ctx, &cwList, client.MatchingFields{".spec.secret.name": s.Name}, client.InNamespace(s.Namespace))
 	if err != nil {
 		log.Error(err, secretlogname+" Unable to get CertWatcher list")
 	}
-	cwListLen := len(cwList.Items)
+	selecting, selectingErr := r.updateSelectingCertWatchers(ctx, &s)
+	cwListLen := len(cwList.Items) + selecting
+	var updateErr error
 	if cwListLen > 0 {
 		for _, cw := range cwList.Items {
+			if !util.SecretTypeSupported(&s, cw.Spec.Secret.Keys) {
+				continue
+			}
+			// Each CertWatcher chooses what its checksum includes.
+			dataChecksum, err := util.SecretChecksum(&s, cw.Spec.Secret.Keys, cw.Spec.Checksum)
+			if err != nil {
+				log.Error(err, secretlogname+" Unable to calculate checksum for CertWatcher "+cw.Namespace+"/"+cw.Name)
+				r.EventRecorder.Eventf(&cw, "Warning", "SecretChanged", "Unable to calculate Secret checksum: %s", err.Error())
+				continue
+			}
 			if cw.Status.Status != "Ready" {
 				r.EventRecorder.Eventf(&cw, "Warning", "SecretChanged", "Secret changed, but CertWatcher not Ready.")
 				// return ctrl.Result{Requeue: true, RequeueAfter: retryPeriod}, err
 			}
+			if cw.Status.SecretDeleted != nil {
+				if err = r.secretRecreated(ctx, &cw, dataChecksum); err != nil {
+					updateErr = err
+				}
+				continue
+			}
 			if cw.Status.ActionStatus == "Pending" {
 				r.EventRecorder.Eventf(&cw, "Warning", "SecretChanged", "Secret changed, but CertWatcher has Pending actions.")
 				// return ctrl.Result{Requeue: true, RequeueAfter: retryPeriod}, err
@@ -86,17 +103,106 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 				cw.Status.LastChecksum = dataChecksum
 				cw.Status.Message = "Checksum updated"
 				cw.Status.ActionStatus = "Pending"
+				cw.Status.PendingActions = nil
 				r.EventRecorder.Eventf(&cw, "Normal", "SecretChanged", "Updating CertWatcher status.")
-				// return r.updateCertWatcher(ctx, &cw)
-				r.updateCertWatcher(ctx, &cw)
+				if _, err = r.updateCertWatcher(ctx, &cw); err != nil {
+					updateErr = err
+				}
 			}
 		}
-	} else {
+	} else if s.Type == corev1.SecretTypeTLS {
 		log.Info(secretlogname + " Secret does not seem to have any CertWatchers")
 	}
+	// Updates lost to conflicts with a stale cache are retried with the Secret.
+	if updateErr == nil {
+		updateErr = selectingErr
+	}
+	if updateErr != nil {
+		return ctrl.Result{Requeue: true}, updateErr
+	}
 	return ctrl.Result{}, nil
 }
 
+// updateSelectingCertWatchers updates the CertWatchers whose selector matches
+// the Secret, adding it to their list of Secrets or marking it Pending when
+// its checksum changed. Secrets that no longer match are removed from the
+// list. Returns the number of CertWatchers selecting the Secret, and the last
+// error updating any of them, so the Secret can be processed again.
+func (r *SecretReconciler) updateSelectingCertWatchers(ctx context.Context, s *corev1.Secret) (int, error) {
+	var secretlogname string = s.Namespace + "/" + s.Name
+	var cwList certwatchv1.CertWatcherList
+	err := r.List(ctx, &cwList, client.InNamespace(s.Namespace))
+	if err != nil {
+		log.Error(err, secretlogname+" Unable to get CertWatcher list")
+		return 0, err
+	}
+	var selecting int
+	var updateErr error
+	for _, cw := range cwList.Items {
+		if cw.Spec.Secret.Selector == nil || cw.Spec.Secret.Namespace != s.Namespace || !util.SecretTypeSupported(s, cw.Spec.Secret.Keys) {
+			continue
+		}
+		selector, err := apimachineryv1.LabelSelectorAsSelector(cw.Spec.Secret.Selector)
+		if err != nil {
+			log.Error(err, cw.Namespace+"/"+cw.Name+" Invalid secret selector")
+			continue
+		}
+		var index = -1
+		for i, entry := range cw.Status.Secrets {
+			if entry.Name == s.Name {
+				index = i
+				break
+			}
+		}
+		if !selector.Matches(labels.Set(s.Labels)) {
+			if index >= 0 {
+				cw.Status.Secrets = append(cw.Status.Secrets[:index], cw.Status.Secrets[index+1:]...)
+				r.EventRecorder.Eventf(&cw, "Normal", "SecretChanged", "Secret %s no longer selected.", secretlogname)
+				if _, err = r.updateCertWatcher(ctx, &cw); err != nil {
+					updateErr = err
+				}
+			}
+			continue
+		}
+		selecting++
+		if cw.Status.Status != "Ready" {
+			r.EventRecorder.Eventf(&cw, "Warning", "SecretChanged", "Secret %s changed, but CertWatcher not Ready.", secretlogname)
+			continue
+		}
+		dataChecksum, err := util.SecretChecksum(s, cw.Spec.Secret.Keys, cw.Spec.Checksum)
+		if err != nil {
+			log.Error(err, secretlogname+" Unable to calculate checksum for CertWatcher "+cw.Namespace+"/"+cw.Name)
+			r.EventRecorder.Eventf(&cw, "Warning", "SecretChanged", "Unable to calculate checksum of Secret %s: %s", secretlogname, err.Error())
+			continue
+		}
+		if index >= 0 && cw.Status.Secrets[index].Checksum == dataChecksum {
+			continue
+		}
+		var entry = certwatchv1.CertWatcherSecretStatus{
+			Name:         s.Name,
+			Checksum:     dataChecksum,
+			ActionStatus: "Pending",
+			Message:      "Checksum updated",
+			LastUpdate:   apimachineryv1.Now(),
+		}
+		if index >= 0 {
+			cw.Status.Secrets[index] = entry
+		} else {
+			entry.Message = "Secret selected"
+			cw.Status.Secrets = append(cw.Status.Secrets, entry)
+		}
+		cw.Status.LastChecksum = dataChecksum
+		cw.Status.Message = "Checksum updated for Secret " + secretlogname
+		cw.Status.ActionStatus = "Pending"
+		cw.Status.PendingActions = nil
+		r.EventRecorder.Eventf(&cw, "Normal", "SecretChanged", "Updating CertWatcher status for Secret %s.", secretlogname)
+		if _, err = r.updateCertWatcher(ctx, &cw); err != nil {
+			updateErr = err
+		}
+	}
+	return selecting, updateErr
+}
+
 var rateLimiter ratelimiter.RateLimiter = workqueue.NewItemFastSlowRateLimiter(retryFastDelay, retrySlowDelay, retryMaxFastAttempts)
 
 // SetupWithManager sets up the controller with the Manager.
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+)
+
+// secretDeleted updates the CertWatchers watching a deleted Secret. Those
+// watching it by name get the SecretMissing condition, and their onDelete
+// actions Pending. Those selecting it stop tracking it. Returns the last error
+// updating any of them, so the deletion can be processed again.
+func (r *SecretReconciler) secretDeleted(ctx context.Context, name types.NamespacedName) error {
+	var secretlogname string = name.String()
+	var cwList certwatchv1.CertWatcherList
+	err := r.List(ctx, &cwList, client.InNamespace(name.Namespace))
+	if err != nil {
+		log.Error(err, secretlogname+" Unable to get CertWatcher list")
+		return err
+	}
+	var updateErr error
+	for _, cw := range cwList.Items {
+		if cw.Spec.Secret.Namespace != name.Namespace {
+			continue
+		}
+		if cw.Spec.Secret.Selector != nil {
+			for i, entry := range cw.Status.Secrets {
+				if entry.Name == name.Name {
+					cw.Status.Secrets = append(cw.Status.Secrets[:i], cw.Status.Secrets[i+1:]...)
+					r.EventRecorder.Eventf(&cw, "Normal", "SecretDeleted", "Secret %s deleted, no longer selected.", secretlogname)
+					if _, err = r.updateCertWatcher(ctx, &cw); err != nil {
+						updateErr = err
+					}
+					break
+				}
+			}
+			continue
+		}
+		if cw.Spec.Secret.Name != name.Name || cw.Status.Status != "Ready" || cw.Status.SecretDeleted != nil {
+			continue
+		}
+		cw.Status.SecretDeleted = &certwatchv1.CertWatcherSecretDeletedStatus{DeletedAt: apimachineryv1.Now()}
+		if onDelete := cw.Spec.Secret.OnDelete; onDelete != nil && (onDelete.Echo != nil || onDelete.Email != nil) {
+			cw.Status.SecretDeleted.ActionStatus = "Pending"
+		}
+		meta.SetStatusCondition(&cw.Status.Conditions, apimachineryv1.Condition{
+			Type:               certwatchv1.CertWatcherConditionSecretMissing,
+			Status:             apimachineryv1.ConditionTrue,
+			Reason:             "SecretDeleted",
+			Message:            "Secret " + secretlogname + " deleted",
+			ObservedGeneration: cw.Generation,
+		})
+		cw.Status.Message = "Secret " + secretlogname + " deleted"
+		r.EventRecorder.Eventf(&cw, "Warning", "SecretDeleted", "Secret %s deleted.", secretlogname)
+		if _, err = r.updateCertWatcher(ctx, &cw); err != nil {
+			updateErr = err
+		}
+	}
+	return updateErr
+}
+
+// secretRecreated updates a CertWatcher whose deleted Secret was created
+// again. Depending on its onRecreate policy, actions are Pending as for any
+// change, or the checksum of the new Secret is just recorded, dropping actions
+// left Pending when it was deleted. Returns the error updating the
+// CertWatcher, so the re-creation can be processed again.
+func (r *SecretReconciler) secretRecreated(ctx context.Context, cw *certwatchv1.CertWatcher, dataChecksum string) error {
+	var secretlogname string = cw.Spec.Secret.Namespace + "/" + cw.Spec.Secret.Name
+	cw.Status.SecretDeleted = nil
+	meta.SetStatusCondition(&cw.Status.Conditions, apimachineryv1.Condition{
+		Type:               certwatchv1.CertWatcherConditionSecretMissing,
+		Status:             apimachineryv1.ConditionFalse,
+		Reason:             "SecretRecreated",
+		Message:            "Secret " + secretlogname + " created again",
+		ObservedGeneration: cw.Generation,
+	})
+	cw.Status.LastChecksum = dataChecksum
+	if cw.Spec.Secret.OnRecreate == "Rebaseline" {
+		cw.Status.Message = "Secret created again, checksum updated without actions"
+		if cw.Status.ActionStatus == "Pending" {
+			cw.Status.ActionStatus = "Ready"
+			cw.Status.PendingActions = nil
+		}
+		r.EventRecorder.Eventf(cw, "Normal", "SecretChanged", "Secret %s created again, updating checksum only.", secretlogname)
+	} else {
+		cw.Status.Message = "Secret created again"
+		cw.Status.ActionStatus = "Pending"
+		cw.Status.PendingActions = nil
+		r.EventRecorder.Eventf(cw, "Normal", "SecretChanged", "Secret %s created again, updating CertWatcher status.", secretlogname)
+	}
+	_, err := r.updateCertWatcher(ctx, cw)
+	return err
+}
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ParseCertificates parses all PEM encoded certificates, in the order they
+// appear. The first certificate is expected to be the leaf certificate.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse certificate: %s", err.Error())
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificates found")
+	}
+	return certs, nil
+}
+
+// ParsePrivateKey parses a PEM encoded private key, either PKCS#1 (RSA),
+// SEC 1 (EC) or unencrypted PKCS#8.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no PEM encoded private key found")
+		}
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			signer, ok := key.(crypto.Signer)
+			if !ok {
+				return nil, fmt.Errorf("unsupported private key type %T", key)
+			}
+			return signer, nil
+		}
+	}
+}
+
+// KeyMatchesCertificate reports whether the private key belongs to the
+// certificate.
+func KeyMatchesCertificate(key crypto.Signer, cert *x509.Certificate) bool {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		return pub.Equal(cert.PublicKey)
+	case *ecdsa.PublicKey:
+		return pub.Equal(cert.PublicKey)
+	case ed25519.PublicKey:
+		certPub, ok := cert.PublicKey.(ed25519.PublicKey)
+		return ok && bytes.Equal(pub, certPub)
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package util
+
+import (
+	"fmt"
+
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+)
+
+// CertificateGroupVersionKind is the kind of cert-manager Certificates. They
+// are handled as unstructured objects, so cert-manager is not a dependency.
+var CertificateGroupVersionKind = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// NewCertificate returns an empty unstructured cert-manager Certificate, to
+// read one from the API.
+func NewCertificate() *unstructured.Unstructured {
+	var certificate = &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(CertificateGroupVersionKind)
+	return certificate
+}
+
+// CertificateState is what CertWatchers need from a cert-manager Certificate.
+type CertificateState struct {
+	// SecretName is spec.secretName, the Secret the certificate is stored in.
+	SecretName string
+
+	// Revision is status.revision, incremented on every issuance. Zero
+	// before the first one.
+	Revision int64
+
+	// Ready is the status of the Ready condition, Unknown when missing,
+	// along with its reason and message.
+	Ready   apimachineryv1.ConditionStatus
+	Reason  string
+	Message string
+}
+
+// CertificateReadyCondition is the CertificateReady condition of a
+// CertWatcher, mirroring the Ready condition of its Certificate.
+func CertificateReadyCondition(state CertificateState, generation int64) apimachineryv1.Condition {
+	var condition = apimachineryv1.Condition{
+		Type:               certwatchv1.CertWatcherConditionCertificateReady,
+		Status:             state.Ready,
+		Reason:             state.Reason,
+		Message:            state.Message,
+		ObservedGeneration: generation,
+	}
+	// Conditions require a reason, which cert-manager may not have set yet.
+	if condition.Reason == "" {
+		condition.Reason = "Unknown"
+	}
+	return condition
+}
+
+// GetCertificateState reads the state of an unstructured cert-manager
+// Certificate.
+func GetCertificateState(certificate *unstructured.Unstructured) (CertificateState, error) {
+	var state = CertificateState{Ready: apimachineryv1.ConditionUnknown}
+	var certificatename = certificate.GetNamespace() + "/" + certificate.GetName()
+
+	secretName, found, err := unstructured.NestedString(certificate.Object, "spec", "secretName")
+	if err != nil || !found || secretName == "" {
+		return state, fmt.Errorf("certificate %s does not have spec.secretName", certificatename)
+	}
+	state.SecretName = secretName
+
+	revision, _, err := unstructured.NestedInt64(certificate.Object, "status", "revision")
+	if err != nil {
+		return state, fmt.Errorf("certificate %s has invalid status.revision: %s", certificatename, err.Error())
+	}
+	state.Revision = revision
+
+	conditions, _, err := unstructured.NestedSlice(certificate.Object, "status", "conditions")
+	if err != nil {
+		return state, fmt.Errorf("certificate %s has invalid status.conditions: %s", certificatename, err.Error())
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		if status, ok := condition["status"].(string); ok {
+			state.Ready = apimachineryv1.ConditionStatus(status)
+		}
+		state.Reason, _ = condition["reason"].(string)
+		state.Message, _ = condition["message"].(string)
+	}
+	return state, nil
+}
@@ -0,0 +1,114 @@
+package util
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// CertificateChain is the chain of a leaf certificate, ordered from the leaf
+// up to the root.
+type CertificateChain struct {
+	// Leaf is the certificate matching the private key.
+	Leaf *x509.Certificate
+
+	// Intermediates are the certificates between the leaf and the root, each
+	// one issued by the next.
+	Intermediates []*x509.Certificate
+
+	// Root is the self-signed certificate at the top of the chain, or nil if it
+	// is not available. For a self-signed leaf certificate, Root is the leaf.
+	Root *x509.Certificate
+}
+
+// BuildCertificateChain orders the certificates from tls.crt and ca.crt into
+// the chain of the leaf certificate, following issuers and checking their
+// signatures. Certificates from tls.crt that are not part of the chain are
+// returned separately. Unused ca.crt certificates are ignored, as ca.crt often
+// holds a bundle of CAs.
+func BuildCertificateChain(leaf *x509.Certificate, certs []*x509.Certificate, cas []*x509.Certificate) (*CertificateChain, []*x509.Certificate) {
+	var chain = &CertificateChain{Leaf: leaf}
+	var used = map[*x509.Certificate]bool{}
+	var candidates []*x509.Certificate
+	for _, cert := range append(append([]*x509.Certificate{}, certs...), cas...) {
+		if cert.Equal(leaf) {
+			used[cert] = true
+			continue
+		}
+		candidates = append(candidates, cert)
+	}
+
+	var current = leaf
+	for !isSelfSigned(current) {
+		var issuer *x509.Certificate
+		for _, candidate := range candidates {
+			if used[candidate] || !bytes.Equal(candidate.RawSubject, current.RawIssuer) {
+				continue
+			}
+			if current.CheckSignatureFrom(candidate) == nil {
+				issuer = candidate
+				break
+			}
+		}
+		if issuer == nil {
+			break
+		}
+		// Mark duplicates of the issuer as used as well.
+		for _, candidate := range candidates {
+			if candidate.Equal(issuer) {
+				used[candidate] = true
+			}
+		}
+		if isSelfSigned(issuer) {
+			chain.Root = issuer
+			break
+		}
+		chain.Intermediates = append(chain.Intermediates, issuer)
+		current = issuer
+	}
+	if chain.Root == nil && isSelfSigned(leaf) {
+		chain.Root = leaf
+	}
+
+	var unused []*x509.Certificate
+	for _, cert := range certs {
+		if !used[cert] {
+			unused = append(unused, cert)
+		}
+	}
+	return chain, unused
+}
+
+// FullChain returns the leaf followed by the intermediates, which is what
+// servers are expected to send to clients.
+func (c *CertificateChain) FullChain() []*x509.Certificate {
+	return append([]*x509.Certificate{c.Leaf}, c.Intermediates...)
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// EncodeCertificates encodes certificates in PEM format.
+func EncodeCertificates(certs []*x509.Certificate) []byte {
+	var buffer bytes.Buffer
+	for _, cert := range certs {
+		_ = pem.Encode(&buffer, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buffer.Bytes()
+}
+
+// validateCertificateChain checks that the chain can be split into files:
+// every certificate in tls.crt must be part of it.
+func validateCertificateChain(chain *CertificateChain, unused []*x509.Certificate) error {
+	if len(unused) > 0 {
+		return fmt.Errorf("certificate %s in tls.crt is not part of the chain of %s", certificateName(unused[0]), certificateName(chain.Leaf))
+	}
+	return nil
+}
+
+// certificateName describes a certificate in error messages.
+func certificateName(cert *x509.Certificate) string {
+	return fmt.Sprintf("%q (serial %s)", cert.Subject.String(), cert.SerialNumber.String())
+}
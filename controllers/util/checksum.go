@@ -3,9 +3,14 @@ package util
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 
 	v1 "k8s.io/api/core/v1"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
 )
 
 // Calculate SHA256 from the Secret data. For simplicity, mashal the entire Data
@@ -27,3 +32,203 @@ func SecretDataChecksum(s *v1.Secret) (string, error) {
 	hash.Write(labelsJson)
 	return base64.URLEncoding.EncodeToString(hash.Sum(nil)), nil
 }
+
+// ConfigMapDataChecksum calculates SHA256 from the ConfigMap data, binary data
+// and labels, the same way SecretDataChecksum does for Secrets.
+func ConfigMapDataChecksum(cm *v1.ConfigMap) (string, error) {
+	dataJson, err := json.Marshal(cm.Data)
+	if err != nil {
+		return "", err
+	}
+	binaryDataJson, err := json.Marshal(cm.BinaryData)
+	if err != nil {
+		return "", err
+	}
+	labelsJson, err := json.Marshal(cm.ObjectMeta.Labels)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write(dataJson)
+	hash.Write(binaryDataJson)
+	hash.Write(labelsJson)
+	return base64.URLEncoding.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Checksum scopes, selecting what is included in the checksum of a watched
+// Secret or ConfigMap.
+const (
+	ChecksumScopeData        = "Data"
+	ChecksumScopeFingerprint = "Fingerprint"
+	ChecksumScopeKeys        = "Keys"
+	ChecksumScopeTrigger     = "Trigger"
+)
+
+// DefaultTriggerAnnotation is the annotation whose value is included in every
+// checksum, unless another one is set, to perform actions again on demand.
+const DefaultTriggerAnnotation = "certwatch.morimoto.net.br/trigger"
+
+// SecretChecksum calculates the checksum of a Secret watched by a CertWatcher,
+// within the scope of its checksum settings. keys are the key names of the
+// Secret, for the Fingerprint scope. With the Data scope, the default, the
+// checksum is the SecretDataChecksum, changed only by the trigger annotation.
+func SecretChecksum(s *v1.Secret, keys *certwatchv1.CertWatcherSecretKeys, spec *certwatchv1.CertWatcherChecksum) (string, error) {
+	var scope, trigger = checksumScope(spec, s.Annotations)
+	switch scope {
+	case ChecksumScopeData:
+		checksum, err := SecretDataChecksum(s)
+		if err != nil {
+			return "", err
+		}
+		return withTrigger(checksum, trigger), nil
+	case ChecksumScopeFingerprint:
+		normalized, err := NormalizeSecretKeys(s, keys)
+		if err != nil {
+			return "", err
+		}
+		return scopedChecksum(spec, normalized.Data, normalized.Data[SecretCertificateKey], trigger)
+	default:
+		return scopedChecksum(spec, s.Data, nil, trigger)
+	}
+}
+
+// ConfigMapChecksum calculates the checksum of a ConfigMap watched by a
+// CertWatcher, with the certificates in key, the same way SecretChecksum does
+// for Secrets. Values of data and binaryData are both available to the Keys
+// scope.
+func ConfigMapChecksum(cm *v1.ConfigMap, key string, spec *certwatchv1.CertWatcherChecksum) (string, error) {
+	var scope, trigger = checksumScope(spec, cm.Annotations)
+	switch scope {
+	case ChecksumScopeData:
+		checksum, err := ConfigMapDataChecksum(cm)
+		if err != nil {
+			return "", err
+		}
+		return withTrigger(checksum, trigger), nil
+	case ChecksumScopeFingerprint:
+		secret, err := ConfigMapAsSecret(cm, key)
+		if err != nil {
+			return "", err
+		}
+		return scopedChecksum(spec, nil, secret.Data[SecretCertificateKey], trigger)
+	default:
+		var data = map[string][]byte{}
+		for k, v := range cm.BinaryData {
+			data[k] = v
+		}
+		for k, v := range cm.Data {
+			data[k] = []byte(v)
+		}
+		return scopedChecksum(spec, data, nil, trigger)
+	}
+}
+
+// checksumScope returns the scope of the checksum settings and the value of
+// their trigger annotation.
+func checksumScope(spec *certwatchv1.CertWatcherChecksum, annotations map[string]string) (string, string) {
+	if spec == nil {
+		return ChecksumScopeData, annotations[DefaultTriggerAnnotation]
+	}
+	return defaultString(spec.Scope, ChecksumScopeData), annotations[defaultString(spec.TriggerAnnotation, DefaultTriggerAnnotation)]
+}
+
+// withTrigger includes the value of the trigger annotation in a checksum. The
+// checksum is unchanged without it, so it matches the one recorded before
+// trigger annotations existed.
+func withTrigger(checksum string, trigger string) string {
+	if trigger == "" {
+		return checksum
+	}
+	hash := sha256.New()
+	hash.Write([]byte(checksum))
+	hash.Write([]byte(trigger))
+	return base64.URLEncoding.EncodeToString(hash.Sum(nil))
+}
+
+// scopedChecksum calculates the checksum for the Fingerprint, Keys and Trigger
+// scopes, from the PEM encoded certificates or the data of the Secret or
+// ConfigMap, respectively.
+func scopedChecksum(spec *certwatchv1.CertWatcherChecksum, data map[string][]byte, certificates []byte, trigger string) (string, error) {
+	var content = map[string]interface{}{"trigger": trigger}
+	switch spec.Scope {
+	case ChecksumScopeFingerprint:
+		certs, err := ParseCertificates(certificates)
+		if err != nil {
+			return "", fmt.Errorf("unable to calculate certificate fingerprints: %s", err.Error())
+		}
+		var fingerprints []string
+		for _, cert := range certs {
+			fingerprint := sha256.Sum256(cert.Raw)
+			fingerprints = append(fingerprints, hex.EncodeToString(fingerprint[:]))
+		}
+		content["fingerprints"] = fingerprints
+	case ChecksumScopeKeys:
+		if len(spec.Keys) == 0 {
+			return "", errors.New("checksum keys are required with the Keys scope")
+		}
+		var values = map[string][]byte{}
+		for _, key := range spec.Keys {
+			if value, ok := data[key]; ok {
+				values[key] = value
+			}
+		}
+		content["keys"] = values
+	case ChecksumScopeTrigger:
+	default:
+		return "", fmt.Errorf("invalid checksum scope %s", spec.Scope)
+	}
+	contentJson, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(contentJson)
+	return base64.URLEncoding.EncodeToString(hash[:]), nil
+}
+
+// ActionChecksums calculates a checksum of the configuration of each action,
+// by name: echo, email, scp and job. Actions not configured are left out.
+func ActionChecksums(actions certwatchv1.CertWatcherAction) (map[string]string, error) {
+	var configured = map[string]interface{}{}
+	if actions.Echo != nil {
+		configured["echo"] = actions.Echo
+	}
+	if actions.Email != nil {
+		configured["email"] = actions.Email
+	}
+	if actions.Scp != nil {
+		configured["scp"] = actions.Scp
+	}
+	if actions.Job != nil {
+		configured["job"] = actions.Job
+	}
+	var checksums = map[string]string{}
+	for name, action := range configured {
+		actionJson, err := json.Marshal(action)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(actionJson)
+		checksums[name] = base64.URLEncoding.EncodeToString(hash[:])
+	}
+	return checksums, nil
+}
+
+// ChecksumSettings calculates a checksum of the settings of the CertWatcher
+// that decide what the checksum of its source includes: the checksum scope and
+// the keys of the certificate data.
+func ChecksumSettings(spec certwatchv1.CertWatcherSpec) (string, error) {
+	var settings = struct {
+		Checksum     *certwatchv1.CertWatcherChecksum
+		SecretKeys   *certwatchv1.CertWatcherSecretKeys
+		ConfigMapKey string
+	}{Checksum: spec.Checksum, SecretKeys: spec.Secret.Keys}
+	if spec.ConfigMap != nil {
+		settings.ConfigMapKey = spec.ConfigMap.Key
+	}
+	settingsJson, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(settingsJson)
+	return base64.URLEncoding.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,176 @@
+package util
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+)
+
+// Checksums recorded by SecretDataChecksum and ConfigMapDataChecksum before
+// checksum scopes existed, which the Data scope must keep producing, so no
+// actions are performed again when upgrading.
+const (
+	checksumTestSecretData    = "nmSDTqgTdQ75xNnrlVA-9ckvSm8lGLv_TFisyMXm2yE="
+	checksumTestConfigMapData = "QPscIBvlK3Se5l0ZYUSjUDt0okHFf01zPbFXyLiSMxI="
+)
+
+func checksumTestSecret() *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: apimachineryv1.ObjectMeta{Namespace: "default", Name: "example-tls", Labels: map[string]string{"app": "example"}},
+		Type:       v1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"cert.pem": []byte(testCertificatePEM),
+			"key.pem":  []byte(testKeyPEM),
+			"other":    []byte("other"),
+		},
+	}
+}
+
+func secretChecksum(t *testing.T, s *v1.Secret, keys *certwatchv1.CertWatcherSecretKeys, spec *certwatchv1.CertWatcherChecksum) string {
+	checksum, err := SecretChecksum(s, keys, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return checksum
+}
+
+func TestSecretChecksumData(t *testing.T) {
+	var secret = &v1.Secret{
+		ObjectMeta: apimachineryv1.ObjectMeta{Namespace: "default", Name: "example-tls", Labels: map[string]string{"app": "example"}},
+		Data:       map[string][]byte{"tls.crt": []byte("certificate"), "tls.key": []byte("private key")},
+	}
+	for _, spec := range []*certwatchv1.CertWatcherChecksum{nil, {}, {Scope: ChecksumScopeData}} {
+		if checksum := secretChecksum(t, secret, nil, spec); checksum != checksumTestSecretData {
+			t.Errorf("%+v: expected %s, got %s", spec, checksumTestSecretData, checksum)
+		}
+	}
+
+	secret.Labels["app"] = "other"
+	if secretChecksum(t, secret, nil, nil) == checksumTestSecretData {
+		t.Error("expected labels to change the Data checksum")
+	}
+	secret.Labels["app"] = "example"
+	secret.Annotations = map[string]string{DefaultTriggerAnnotation: "1"}
+	triggered := secretChecksum(t, secret, nil, nil)
+	if triggered == checksumTestSecretData {
+		t.Error("expected the trigger annotation to change the Data checksum")
+	}
+	secret.Annotations[DefaultTriggerAnnotation] = "2"
+	if secretChecksum(t, secret, nil, nil) == triggered {
+		t.Error("expected a new trigger annotation value to change the Data checksum")
+	}
+}
+
+func TestConfigMapChecksumData(t *testing.T) {
+	var cm = &v1.ConfigMap{
+		ObjectMeta: apimachineryv1.ObjectMeta{Namespace: "default", Name: "example-ca"},
+		Data:       map[string]string{"ca.crt": "bundle"},
+	}
+	checksum, err := ConfigMapChecksum(cm, "ca.crt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != checksumTestConfigMapData {
+		t.Errorf("expected %s, got %s", checksumTestConfigMapData, checksum)
+	}
+}
+
+func TestSecretChecksumFingerprint(t *testing.T) {
+	var keys = &certwatchv1.CertWatcherSecretKeys{Certificate: "cert.pem", PrivateKey: "key.pem"}
+	var spec = &certwatchv1.CertWatcherChecksum{Scope: ChecksumScopeFingerprint}
+	var secret = checksumTestSecret()
+	var checksum = secretChecksum(t, secret, keys, spec)
+
+	// Labels, keys other than the certificate and even the private key do not
+	// change the fingerprints.
+	secret.Labels["app"] = "other"
+	secret.Data["other"] = []byte("changed")
+	secret.Data["key.pem"] = []byte("changed")
+	if changed := secretChecksum(t, secret, keys, spec); changed != checksum {
+		t.Errorf("expected the Fingerprint checksum to stay %s, got %s", checksum, changed)
+	}
+
+	secret.Data["cert.pem"] = []byte(testCertificatePEM + testCertificatePEM)
+	if secretChecksum(t, secret, keys, spec) == checksum {
+		t.Error("expected the certificates to change the Fingerprint checksum")
+	}
+	secret.Data["cert.pem"] = []byte(testCertificatePEM)
+
+	secret.Annotations = map[string]string{"example.com/rotate": "now"}
+	spec.TriggerAnnotation = "example.com/rotate"
+	if secretChecksum(t, secret, keys, spec) == checksum {
+		t.Error("expected the trigger annotation to change the Fingerprint checksum")
+	}
+
+	secret.Data["cert.pem"] = []byte("not a certificate")
+	if _, err := SecretChecksum(secret, keys, spec); err == nil {
+		t.Error("expected an error without certificates")
+	}
+}
+
+func TestSecretChecksumKeys(t *testing.T) {
+	var spec = &certwatchv1.CertWatcherChecksum{Scope: ChecksumScopeKeys, Keys: []string{"cert.pem", "missing"}}
+	var secret = checksumTestSecret()
+	var checksum = secretChecksum(t, secret, nil, spec)
+
+	secret.Labels["app"] = "other"
+	secret.Data["other"] = []byte("changed")
+	secret.Data["key.pem"] = []byte("changed")
+	if changed := secretChecksum(t, secret, nil, spec); changed != checksum {
+		t.Errorf("expected keys outside %v to be ignored, got %s instead of %s", spec.Keys, changed, checksum)
+	}
+	secret.Data["cert.pem"] = []byte("changed")
+	if secretChecksum(t, secret, nil, spec) == checksum {
+		t.Error("expected keys in the Keys scope to change the checksum")
+	}
+
+	secret.Annotations = map[string]string{DefaultTriggerAnnotation: "1"}
+	checksum = secretChecksum(t, secret, nil, spec)
+	secret.Annotations[DefaultTriggerAnnotation] = "2"
+	if secretChecksum(t, secret, nil, spec) == checksum {
+		t.Error("expected the trigger annotation to change the Keys checksum")
+	}
+
+	if _, err := SecretChecksum(secret, nil, &certwatchv1.CertWatcherChecksum{Scope: ChecksumScopeKeys}); err == nil {
+		t.Error("expected an error without keys")
+	}
+}
+
+func TestSecretChecksumTrigger(t *testing.T) {
+	var spec = &certwatchv1.CertWatcherChecksum{Scope: ChecksumScopeTrigger}
+	var secret = checksumTestSecret()
+	var checksum = secretChecksum(t, secret, nil, spec)
+
+	secret.Data["cert.pem"] = []byte("changed")
+	if changed := secretChecksum(t, secret, nil, spec); changed != checksum {
+		t.Errorf("expected the Trigger checksum to stay %s, got %s", checksum, changed)
+	}
+	secret.Annotations = map[string]string{DefaultTriggerAnnotation: "1"}
+	if secretChecksum(t, secret, nil, spec) == checksum {
+		t.Error("expected the trigger annotation to change the Trigger checksum")
+	}
+}
+
+func TestConfigMapChecksumKeys(t *testing.T) {
+	var spec = &certwatchv1.CertWatcherChecksum{Scope: ChecksumScopeKeys, Keys: []string{"ca.crt", "ca.der"}}
+	var cm = &v1.ConfigMap{
+		ObjectMeta: apimachineryv1.ObjectMeta{Namespace: "default", Name: "example-ca"},
+		Data:       map[string]string{"ca.crt": "bundle", "other": "other"},
+		BinaryData: map[string][]byte{"ca.der": []byte("der")},
+	}
+	checksum, err := ConfigMapChecksum(cm, "ca.crt", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.Data["other"] = "changed"
+	if changed, _ := ConfigMapChecksum(cm, "ca.crt", spec); changed != checksum {
+		t.Errorf("expected keys outside %v to be ignored, got %s instead of %s", spec.Keys, changed, checksum)
+	}
+	cm.BinaryData["ca.der"] = []byte("changed")
+	if changed, _ := ConfigMapChecksum(cm, "ca.crt", spec); changed == checksum {
+		t.Error("expected binaryData keys to change the Keys checksum")
+	}
+}
@@ -1,21 +1,245 @@
 package util
 
 import (
+	"crypto/x509"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 
 	apicorev1 "k8s.io/api/core/v1"
 )
 
+// Certificate file formats, used to select which files CreateCertificateFiles
+// creates. Each format results in one or more files named after the filenames
+// prefix:
+//
+// - pem:         tls.key and tls.crt, as found in the Secret
+// - der:         tls.key.der (PKCS#8) and tls.crt.der (leaf certificate only)
+// - p12:         tls.p12 (tls.key and tls.crt included)
+// - crt.p12:     tls.crt.p12 (tls.crt included)
+// - key.zip:     tls.key.zip (tls.key zipped)
+// - crt.zip:     tls.crt.zip (tls.crt zipped)
+// - zip:         tls.zip (tls.crt and tls.key zipped)
+// - p12.zip:     tls.p12.zip (tls.p12 zipped)
+// - crt.p12.zip: tls.crt.p12.zip (tls.crt.p12 zipped)
+// - all.zip:     tls.all.zip (tls.key, tls.crt, tls.p12 and tls.crt.p12 zipped)
+// - jks:            tls.jks (Java keystore with tls.key and tls.crt)
+// - truststore.jks: tls.truststore.jks (Java keystore with the CA chain)
+// - truststore.p12: tls.truststore.p12 (PKCS#12 truststore with the CA chain)
+// - leaf.crt:       tls.leaf.crt (leaf certificate only)
+// - chain.crt:      tls.chain.crt (intermediate certificates, leaf excluded)
+// - ca.crt:         tls.ca.crt (root certificate, from tls.crt or ca.crt)
+// - fullchain.crt:  tls.fullchain.crt (leaf and intermediates)
+// - combined.pem:   tls.combined.pem (fullchain and tls.key, HAProxy style)
+// - pkcs1.key:      tls.pkcs1.key (PKCS#1 for RSA or SEC 1 for EC keys)
+// - pkcs8.key:      tls.pkcs8.key (unencrypted PKCS#8)
+// - encrypted.key:  tls.encrypted.key (PKCS#8 encrypted with the key password)
+//
+// Zip formats also create the files they contain.
+const (
+	FormatPem           = "pem"
+	FormatDer           = "der"
+	FormatP12           = "p12"
+	FormatCrtP12        = "crt.p12"
+	FormatKeyZip        = "key.zip"
+	FormatCrtZip        = "crt.zip"
+	FormatZip           = "zip"
+	FormatP12Zip        = "p12.zip"
+	FormatCrtP12Zip     = "crt.p12.zip"
+	FormatAllZip        = "all.zip"
+	FormatJks           = "jks"
+	FormatTruststoreJks = "truststore.jks"
+	FormatTruststoreP12 = "truststore.p12"
+	FormatLeafCrt       = "leaf.crt"
+	FormatChainCrt      = "chain.crt"
+	FormatCaCrt         = "ca.crt"
+	FormatFullchainCrt  = "fullchain.crt"
+	FormatCombinedPem   = "combined.pem"
+	FormatPkcs1Key      = "pkcs1.key"
+	FormatPkcs8Key      = "pkcs8.key"
+	FormatEncryptedKey  = "encrypted.key"
+)
+
+// DefaultCertificateFormats are the formats created when none are selected.
+var DefaultCertificateFormats = []string{
+	FormatPem,
+	FormatP12,
+	FormatCrtP12,
+	FormatKeyZip,
+	FormatCrtZip,
+	FormatZip,
+	FormatP12Zip,
+	FormatCrtP12Zip,
+	FormatAllZip,
+}
+
+// CertificateOnlyFormats are the formats available for certificates without
+// private key, such as CA bundles. The pem format has tls.crt alone.
+var CertificateOnlyFormats = []string{
+	FormatPem,
+	FormatCrtP12,
+	FormatCrtZip,
+	FormatCrtP12Zip,
+	FormatTruststoreJks,
+	FormatTruststoreP12,
+}
+
+// DefaultCertificateOnlyFormats are the formats created for certificates
+// without private key when none are selected.
+var DefaultCertificateOnlyFormats = []string{
+	FormatPem,
+	FormatCrtP12,
+	FormatCrtZip,
+	FormatCrtP12Zip,
+}
+
+// certificateFormat describes the files of a format, as suffixes of the
+// filenames prefix, and the formats whose files it needs.
+type certificateFormat struct {
+	files    []string
+	requires []string
+}
+
+var certificateFormats = map[string]certificateFormat{
+	FormatPem:           {files: []string{".key", ".crt"}},
+	FormatDer:           {files: []string{".key.der", ".crt.der"}},
+	FormatP12:           {files: []string{".p12"}},
+	FormatCrtP12:        {files: []string{".crt.p12"}},
+	FormatKeyZip:        {files: []string{".key.zip"}, requires: []string{FormatPem}},
+	FormatCrtZip:        {files: []string{".crt.zip"}, requires: []string{FormatPem}},
+	FormatZip:           {files: []string{".zip"}, requires: []string{FormatPem}},
+	FormatP12Zip:        {files: []string{".p12.zip"}, requires: []string{FormatP12}},
+	FormatCrtP12Zip:     {files: []string{".crt.p12.zip"}, requires: []string{FormatCrtP12}},
+	FormatAllZip:        {files: []string{".all.zip"}, requires: []string{FormatPem, FormatP12, FormatCrtP12}},
+	FormatJks:           {files: []string{".jks"}},
+	FormatTruststoreJks: {files: []string{".truststore.jks"}},
+	FormatTruststoreP12: {files: []string{".truststore.p12"}},
+	FormatLeafCrt:       {files: []string{".leaf.crt"}},
+	FormatChainCrt:      {files: []string{".chain.crt"}},
+	FormatCaCrt:         {files: []string{".ca.crt"}},
+	FormatFullchainCrt:  {files: []string{".fullchain.crt"}},
+	FormatCombinedPem:   {files: []string{".combined.pem"}},
+	FormatPkcs1Key:      {files: []string{".pkcs1.key"}},
+	FormatPkcs8Key:      {files: []string{".pkcs8.key"}},
+	FormatEncryptedKey:  {files: []string{".encrypted.key"}},
+}
+
+// certificateZipFiles lists, for each zip format, the files it contains, as
+// suffixes of the filenames prefix.
+var certificateZipFiles = []struct {
+	format string
+	files  []string
+}{
+	{FormatKeyZip, []string{".key"}},
+	{FormatCrtZip, []string{".crt"}},
+	{FormatZip, []string{".key", ".crt"}},
+	{FormatP12Zip, []string{".p12"}},
+	{FormatCrtP12Zip, []string{".crt.p12"}},
+	{FormatAllZip, []string{".key", ".crt", ".p12", ".crt.p12"}},
+}
+
+// resolveCertificateFormats validates the selected formats and adds the ones
+// they depend on. A nil list selects DefaultCertificateFormats, or
+// DefaultCertificateOnlyFormats for certificates without private key.
+func resolveCertificateFormats(formats []string, certificateOnly bool) (map[string]bool, error) {
+	if formats == nil {
+		formats = DefaultCertificateFormats
+		if certificateOnly {
+			formats = DefaultCertificateOnlyFormats
+		}
+	}
+	var resolved = map[string]bool{}
+	for _, format := range formats {
+		f, ok := certificateFormats[format]
+		if !ok {
+			return nil, fmt.Errorf("invalid certificate format %s", format)
+		}
+		if certificateOnly && !isCertificateOnlyFormat(format) {
+			return nil, fmt.Errorf("certificate format %s requires a private key", format)
+		}
+		resolved[format] = true
+		for _, required := range f.requires {
+			resolved[required] = true
+		}
+	}
+	return resolved, nil
+}
+
+func isCertificateOnlyFormat(format string) bool {
+	for _, f := range CertificateOnlyFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// CertificateFileNames returns the names of all files CreateCertificateFiles
+// creates for the given options, so file references in actions can be
+// validated beforehand.
+func CertificateFileNames(options CertificateFilesOptions) ([]string, error) {
+	formats, err := resolveCertificateFormats(options.Formats, options.CertificateOnly)
+	if err != nil {
+		return nil, err
+	}
+	var filenamesPrefix = options.FilenamesPrefix
+	if filenamesPrefix == "" {
+		filenamesPrefix = "tls"
+	}
+	var names []string
+	for format := range formats {
+		for _, suffix := range certificateFormats[format].files {
+			if options.CertificateOnly && suffix == ".key" {
+				continue
+			}
+			names = append(names, filenamesPrefix+suffix)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CertificateFilesOptions controls how CreateCertificateFiles exports
+// certificates.
+type CertificateFilesOptions struct {
+	// FilenamesPrefix of all files. Defaults to "tls".
+	FilenamesPrefix string
+
+	// Formats selects which files are created. If nil, all
+	// DefaultCertificateFormats are created. If empty, no files are created.
+	Formats []string
+
+	// Zip configures the encryption and compression of zip files, password
+	// included.
+	Zip ZipOptions
+
+	// Pkcs12 configures the encoding of p12 files, password included.
+	Pkcs12 Pkcs12Options
+
+	// Keystore configures Java keystore and truststore files, passwords
+	// included.
+	Keystore KeystoreOptions
+
+	// KeyPassword encrypts the private key in the encrypted.key format, which
+	// requires it.
+	KeyPassword string
+
+	// CertificateOnly creates files from tls.crt alone, for certificates
+	// without private key such as CA bundles. Only CertificateOnlyFormats are
+	// allowed.
+	CertificateOnly bool
+}
+
 // CreateCertificateFiles Export certificates from the Secret, namely tls.key and tls.crt, into a
 // temporary working directory. The path for this temporary directory will be
 // returned and is expected to be removed after CertWatcher Action processing is
 // completed.
 //
-// Along with the original tls.key and tls.crt files, additional converted
-// versions of the same files will be included. The full list:
+// Only files for the formats selected in options.Formats are created (see
+// FormatPem and others). By default, along with the original tls.key and
+// tls.crt files, additional converted versions of the same files will be
+// included. The full list:
 //
 // - tls.key
 // - tls.crt
@@ -31,31 +255,54 @@ import (
 // - tls.crt.p12.zip (tls.crt.p12 zipped)
 // - tls.all.zip     (tls.key, tls.crt, tls.p12 and tls.crt.p12 zipped)
 //
-// The "tls" filename prefix is determined by the filenamesPrefix argument.
+// The "tls" filename prefix is determined by options.FilenamesPrefix.
 //
-// If a pkcs12Password is provided, *.p12 files will be created with that password.
+// PKCS#12 files are encoded in-process according to options.Pkcs12 (see
+// EncodePkcs12). If a password is provided there, *.p12 files will be
+// protected with that password.
 //
-// If a zipFilesPassword is provided, *.zip files will be created with that
-// password.
+// The certificates from tls.crt, along with ca.crt when the Secret has it, are
+// ordered into the chain of the certificate matching tls.key (see
+// BuildCertificateChain). Chain files (leaf.crt, chain.crt, ca.crt,
+// fullchain.crt and combined.pem) require every certificate in tls.crt to be
+// part of that chain, and ca.crt requires the root certificate to be found.
+//
+// With options.CertificateOnly, files are created from tls.crt alone, and
+// only CertificateOnlyFormats are allowed. Truststores then have all
+// certificates in tls.crt.
+//
+// Zip files are also created in-process, according to options.Zip (see
+// ZipFiles). If a password is provided there, *.zip files will be encrypted
+// with that password.
 //
 // If the function finishes successfully, creating all files, the path of the
 // temporary working directory followed by a nil error is returned. Otherwise,
 // an empty string followed by the error is returned.
-func CreateCertificateFiles(secret *apicorev1.Secret, filenamesPrefix string, zipFilesPassword string, pkcs12Password string) (string, error) {
+func CreateCertificateFiles(secret *apicorev1.Secret, options CertificateFilesOptions) (string, error) {
 	var err error
 	var secretname string = fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)
-	var filename string
-	var cmd *exec.Cmd
+	var filenamesPrefix = options.FilenamesPrefix
 
 	if filenamesPrefix == "" {
 		filenamesPrefix = "tls"
 	}
 
+	formats, err := resolveCertificateFormats(options.Formats, options.CertificateOnly)
+	if err != nil {
+		return "", fmt.Errorf("CreateCertificateFiles: %s", err.Error())
+	}
+
 	workspacedir, err := os.MkdirTemp("", "certwatch")
 	if err != nil {
 		return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create temporary directory: %s", err.Error())
 	}
 	// defer os.Remove(workspacedir)
+	if len(formats) == 0 {
+		return workspacedir, nil
+	}
+	if options.CertificateOnly {
+		return workspacedir, createCertificateOnlyFiles(workspacedir, filenamesPrefix, secret, formats, options)
+	}
 
 	tlsKey, ok := secret.Data["tls.key"]
 	if !ok {
@@ -67,143 +314,295 @@ func CreateCertificateFiles(secret *apicorev1.Secret, filenamesPrefix string, zi
 		return workspacedir, fmt.Errorf("secret %s does not have value for tls.crt", secretname)
 	}
 
-	filename = filepath.Join(workspacedir, filenamesPrefix+".key")
-	err = os.WriteFile(filename, []byte(tlsKey), 0600)
+	key, err := ParsePrivateKey(tlsKey)
 	if err != nil {
-		return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create %s.key: %s", filenamesPrefix, err.Error())
+		return workspacedir, fmt.Errorf("CreateCertificateFiles cannot parse tls.key from secret %s: %s", secretname, err.Error())
 	}
-
-	filename = filepath.Join(workspacedir, filenamesPrefix+".crt")
-	err = os.WriteFile(filename, []byte(tlsCrt), 0600)
-	if err != nil {
-		return workspacedir, fmt.Errorf("CreateCertificateFiles cannot %s.crt: %s", filenamesPrefix, err.Error())
-	}
-
-	// openssl pkcs12 -export -out tls.p12 -in tls.crt -inkey tls.key -passin pass:changeit
-
-	cmd = exec.Command(
-		"openssl",
-		"pkcs12",
-		"-export",
-		"-out", workspacedir+"/"+filenamesPrefix+".p12",
-		"-in", workspacedir+"/"+filenamesPrefix+".crt",
-		"-inkey", workspacedir+"/"+filenamesPrefix+".key",
-		"-passout", "pass:"+pkcs12Password,
-	)
-	// fmt.Println(cmd)
-	cmdoutput, err := cmd.CombinedOutput()
-	if err != nil {
-		return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create "+filenamesPrefix+".p12: %s\n%s", err.Error(), cmdoutput)
-	}
-
-	cmd = exec.Command(
-		"openssl",
-		"pkcs12",
-		"-export",
-		"-nokeys",
-		"-out", workspacedir+"/"+filenamesPrefix+".crt.p12",
-		"-in", workspacedir+"/"+filenamesPrefix+".crt",
-		"-passout", "pass:"+pkcs12Password,
-	)
-	// fmt.Println(cmd)
-	cmdoutput, err = cmd.CombinedOutput()
+	certs, err := ParseCertificates(tlsCrt)
 	if err != nil {
-		return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create "+filenamesPrefix+".crt.p12: %s\n%s", err.Error(), cmdoutput)
+		return workspacedir, fmt.Errorf("CreateCertificateFiles cannot parse tls.crt from secret %s: %s", secretname, err.Error())
 	}
+	var leaf *x509.Certificate
+	for _, cert := range certs {
+		if KeyMatchesCertificate(key, cert) {
+			leaf = cert
+			break
+		}
+	}
+	if leaf == nil {
+		return workspacedir, fmt.Errorf("CreateCertificateFiles: tls.key from secret %s does not match any tls.crt certificate", secretname)
+	}
+	var cas []*x509.Certificate
+	if caCrt, ok := secret.Data["ca.crt"]; ok && len(caCrt) > 0 {
+		cas, err = ParseCertificates(caCrt)
+		if err != nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot parse ca.crt from secret %s: %s", secretname, err.Error())
+		}
+	}
+	chain, unused := BuildCertificateChain(leaf, certs, cas)
 
-	err = zipFiles(
-		workspacedir,
-		filenamesPrefix+".key.zip",
-		zipFilesPassword,
-		[]string{
-			filenamesPrefix + ".key",
-		},
-	)
-	if err != nil {
-		return workspacedir, err
+	// Keystores get the leaf first, followed by the remaining tls.crt
+	// certificates as they are.
+	var keystoreCerts = []*x509.Certificate{leaf}
+	for _, cert := range certs {
+		if cert != leaf {
+			keystoreCerts = append(keystoreCerts, cert)
+		}
 	}
 
-	err = zipFiles(
-		workspacedir,
-		filenamesPrefix+".crt.zip",
-		zipFilesPassword,
-		[]string{
-			filenamesPrefix + ".crt",
-		},
-	)
-	if err != nil {
-		return workspacedir, err
-	}
-
-	err = zipFiles(
-		workspacedir,
-		filenamesPrefix+".zip",
-		zipFilesPassword,
-		[]string{
-			filenamesPrefix + ".key",
-			filenamesPrefix + ".crt",
-		},
-	)
-	if err != nil {
-		return workspacedir, err
+	writeFile := func(suffix string, data []byte) error {
+		err := os.WriteFile(filepath.Join(workspacedir, filenamesPrefix+suffix), data, 0600)
+		if err != nil {
+			return fmt.Errorf("CreateCertificateFiles cannot create %s%s: %s", filenamesPrefix, suffix, err.Error())
+		}
+		return nil
 	}
 
-	err = zipFiles(
-		workspacedir,
-		filenamesPrefix+".p12.zip",
-		zipFilesPassword,
-		[]string{
-			filenamesPrefix + ".p12",
-		},
-	)
-	if err != nil {
-		return workspacedir, err
+	if formats[FormatPem] {
+		if err = writeFile(".key", tlsKey); err != nil {
+			return workspacedir, err
+		}
+		if err = writeFile(".crt", tlsCrt); err != nil {
+			return workspacedir, err
+		}
 	}
 
-	err = zipFiles(
-		workspacedir,
-		filenamesPrefix+".crt.p12.zip",
-		zipFilesPassword,
-		[]string{
-			filenamesPrefix + ".crt.p12",
-		},
-	)
-	if err != nil {
-		return workspacedir, err
-	}
-
-	err = zipFiles(
-		workspacedir,
-		filenamesPrefix+".all.zip",
-		zipFilesPassword,
-		[]string{
-			filenamesPrefix + ".key",
-			filenamesPrefix + ".crt",
-			filenamesPrefix + ".p12",
-			filenamesPrefix + ".crt.p12",
-		},
-	)
-	if err != nil {
-		return workspacedir, err
+	if formats[FormatDer] {
+		keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create %s.key.der: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".key.der", keyDer); err != nil {
+			return workspacedir, err
+		}
+		if err = writeFile(".crt.der", leaf.Raw); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatPkcs1Key] {
+		keyPem, err := EncodePKCS1PrivateKey(key)
+		if err != nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create %s.pkcs1.key: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".pkcs1.key", keyPem); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatPkcs8Key] {
+		keyPem, err := EncodePKCS8PrivateKey(key)
+		if err != nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create %s.pkcs8.key: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".pkcs8.key", keyPem); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatEncryptedKey] {
+		keyPem, err := EncodeEncryptedPKCS8PrivateKey(key, options.KeyPassword)
+		if err != nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create %s.encrypted.key: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".encrypted.key", keyPem); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatP12] {
+		p12, err := EncodePkcs12(key, keystoreCerts, options.Pkcs12)
+		if err != nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create %s.p12: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".p12", p12); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatCrtP12] {
+		p12, err := EncodePkcs12(nil, keystoreCerts, options.Pkcs12)
+		if err != nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create %s.crt.p12: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".crt.p12", p12); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	var keystoreOptions = defaultKeystoreOptions(options.Keystore, filenamesPrefix)
+
+	if formats[FormatJks] {
+		jks, err := EncodeJKS(key, keystoreCerts, keystoreOptions.Alias, keystoreOptions.Password)
+		if err != nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create %s.jks: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".jks", jks); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatTruststoreJks] {
+		jks, err := EncodeJKSTruststore(TrustedCertificates(chain), keystoreOptions.Alias, keystoreOptions.TruststorePassword)
+		if err != nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create %s.truststore.jks: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".truststore.jks", jks); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatTruststoreP12] {
+		var truststoreOptions = options.Pkcs12
+		truststoreOptions.Password = keystoreOptions.TruststorePassword
+		p12, err := EncodePkcs12Truststore(TrustedCertificates(chain), keystoreOptions.Alias, truststoreOptions)
+		if err != nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create %s.truststore.p12: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".truststore.p12", p12); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatLeafCrt] || formats[FormatChainCrt] || formats[FormatCaCrt] || formats[FormatFullchainCrt] || formats[FormatCombinedPem] {
+		if err = validateCertificateChain(chain, unused); err != nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot split tls.crt from secret %s: %s", secretname, err.Error())
+		}
 	}
 
+	if formats[FormatLeafCrt] {
+		if err = writeFile(".leaf.crt", EncodeCertificates([]*x509.Certificate{leaf})); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatChainCrt] {
+		if err = writeFile(".chain.crt", EncodeCertificates(chain.Intermediates)); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatCaCrt] {
+		if chain.Root == nil {
+			return workspacedir, fmt.Errorf("CreateCertificateFiles cannot create %s.ca.crt: root certificate of %s not found in tls.crt or ca.crt from secret %s", filenamesPrefix, certificateName(leaf), secretname)
+		}
+		if err = writeFile(".ca.crt", EncodeCertificates([]*x509.Certificate{chain.Root})); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatFullchainCrt] {
+		if err = writeFile(".fullchain.crt", EncodeCertificates(chain.FullChain())); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	if formats[FormatCombinedPem] {
+		if err = writeFile(".combined.pem", append(EncodeCertificates(chain.FullChain()), tlsKey...)); err != nil {
+			return workspacedir, err
+		}
+	}
+
+	err = zipCertificateFiles(workspacedir, filenamesPrefix, formats, options.Zip)
 	return workspacedir, err
 }
 
-func zipFiles(workspacedir string, zipfilename string, password string, files []string) error {
-	var cmdargs []string
-	var cmd *exec.Cmd
-	if password != "" {
-		cmdargs = append(cmdargs, "-P", password)
-	}
-	cmdargs = append(cmdargs, zipfilename)
-	cmdargs = append(cmdargs, files...)
-	cmd = exec.Command("zip", cmdargs...)
-	cmd.Dir = workspacedir
-	// fmt.Println(cmd)
-	cmdoutput, err := cmd.CombinedOutput()
+// createCertificateOnlyFiles creates the files of CertificateFilesOptions
+// CertificateOnly, from the certificates in tls.crt. All of them are trusted
+// certificates, so truststores have them all.
+func createCertificateOnlyFiles(workspacedir string, filenamesPrefix string, secret *apicorev1.Secret, formats map[string]bool, options CertificateFilesOptions) error {
+	var secretname string = fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)
+	tlsCrt, ok := secret.Data["tls.crt"]
+	if !ok {
+		return fmt.Errorf("secret %s does not have value for tls.crt", secretname)
+	}
+	certs, err := ParseCertificates(tlsCrt)
 	if err != nil {
-		return fmt.Errorf("CreateCertificateFiles cannot create %s: %s\n%s", zipfilename, err.Error(), cmdoutput)
+		return fmt.Errorf("CreateCertificateFiles cannot parse tls.crt from secret %s: %s", secretname, err.Error())
+	}
+
+	writeFile := func(suffix string, data []byte) error {
+		err := os.WriteFile(filepath.Join(workspacedir, filenamesPrefix+suffix), data, 0600)
+		if err != nil {
+			return fmt.Errorf("CreateCertificateFiles cannot create %s%s: %s", filenamesPrefix, suffix, err.Error())
+		}
+		return nil
+	}
+
+	if formats[FormatPem] {
+		if err = writeFile(".crt", tlsCrt); err != nil {
+			return err
+		}
+	}
+
+	if formats[FormatCrtP12] {
+		p12, err := EncodePkcs12(nil, certs, options.Pkcs12)
+		if err != nil {
+			return fmt.Errorf("CreateCertificateFiles cannot create %s.crt.p12: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".crt.p12", p12); err != nil {
+			return err
+		}
+	}
+
+	var keystoreOptions = defaultKeystoreOptions(options.Keystore, filenamesPrefix)
+
+	if formats[FormatTruststoreJks] {
+		jks, err := EncodeJKSTruststore(certs, keystoreOptions.Alias, keystoreOptions.TruststorePassword)
+		if err != nil {
+			return fmt.Errorf("CreateCertificateFiles cannot create %s.truststore.jks: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".truststore.jks", jks); err != nil {
+			return err
+		}
+	}
+
+	if formats[FormatTruststoreP12] {
+		var truststoreOptions = options.Pkcs12
+		truststoreOptions.Password = keystoreOptions.TruststorePassword
+		p12, err := EncodePkcs12Truststore(certs, keystoreOptions.Alias, truststoreOptions)
+		if err != nil {
+			return fmt.Errorf("CreateCertificateFiles cannot create %s.truststore.p12: %s", filenamesPrefix, err.Error())
+		}
+		if err = writeFile(".truststore.p12", p12); err != nil {
+			return err
+		}
+	}
+
+	return zipCertificateFiles(workspacedir, filenamesPrefix, formats, options.Zip)
+}
+
+// defaultKeystoreOptions fills in the alias and passwords of keystores and
+// truststores left empty.
+func defaultKeystoreOptions(keystoreOptions KeystoreOptions, filenamesPrefix string) KeystoreOptions {
+	if keystoreOptions.Alias == "" {
+		keystoreOptions.Alias = filenamesPrefix
+	}
+	if keystoreOptions.Password == "" {
+		keystoreOptions.Password = DefaultKeystorePassword
+	}
+	if keystoreOptions.TruststorePassword == "" {
+		keystoreOptions.TruststorePassword = keystoreOptions.Password
+	}
+	return keystoreOptions
+}
+
+// zipCertificateFiles creates the zip files of the selected formats, from
+// files already in the workspace directory.
+func zipCertificateFiles(workspacedir string, filenamesPrefix string, formats map[string]bool, options ZipOptions) error {
+	for _, zipFile := range certificateZipFiles {
+		if !formats[zipFile.format] {
+			continue
+		}
+		var files []string
+		for _, suffix := range zipFile.files {
+			files = append(files, filenamesPrefix+suffix)
+		}
+		zipfilename := filenamesPrefix + certificateFormats[zipFile.format].files[0]
+		err := ZipFiles(workspacedir, zipfilename, files, options)
+		if err != nil {
+			return fmt.Errorf("CreateCertificateFiles cannot create %s: %s", zipfilename, err.Error())
+		}
 	}
 	return nil
 }
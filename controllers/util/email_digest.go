@@ -0,0 +1,266 @@
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/magiconair/properties"
+	mail "github.com/xhit/go-simple-mail/v2"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	EmailDigestQueued   = "Queued"
+	EmailDigestSent     = "Sent"
+	EmailDigestRetrying = "Retrying"
+	EmailDigestFailed   = "Failed"
+)
+
+var defaultEmailDigestWindow = 10 * time.Minute
+
+// EmailDigestEntry is one CertWatcher change buffered for a digest e-mail.
+type EmailDigestEntry struct {
+	// CertWatcher that was notified.
+	CertWatcher types.NamespacedName
+
+	// Secret that changed, in the form namespace/name.
+	Secret string
+
+	// Checksum of the Secret change.
+	Checksum string
+
+	// Time of the change.
+	Time time.Time
+
+	// Subject of the CertWatcher's own e-mail, used to describe the change.
+	Subject string
+
+	// Attachments, by file name, read from the temporary workspace directory
+	// before it is removed.
+	Attachments map[string][]byte
+}
+
+// EmailDigester buffers e-mail notifications from CertWatchers in digest
+// mode and sends them as a single message per recipient list once the digest
+// window expires. OnDelivered is called after every digest is sent (or fails
+// to be sent), so CertWatchers can track the notification in their status.
+//
+// Digests are kept in memory only. CertWatchers whose notification is still
+// Queued after a controller restart are expected to queue it again.
+//
+// EmailDigester implements manager.Runnable.
+type EmailDigester struct {
+	Sender      *MailSender
+	OnDelivered func(entries []EmailDigestEntry, err error)
+
+	digests map[string]*emailDigest
+	sending map[types.NamespacedName]string
+	mutex   sync.Mutex
+	ctx     context.Context
+}
+
+type emailDigest struct {
+	spec               *certwatchv1.CertWatchActionEmail
+	emailConfiguration *properties.Properties
+	resources          EmailResources
+	entries            []EmailDigestEntry
+}
+
+// Start keeps the context used to send digests until it is done.
+func (d *EmailDigester) Start(ctx context.Context) error {
+	d.mutex.Lock()
+	d.ctx = ctx
+	d.mutex.Unlock()
+	<-ctx.Done()
+	return nil
+}
+
+// Add buffers a change notification for the e-mail action of a CertWatcher.
+// The digest it belongs to is identified by the email configuration, the
+// sender and recipient addresses, the digest subject and attachments setting,
+// and the PGP settings and recipient keys. A newer change from the same
+// CertWatcher replaces the previous one.
+func (d *EmailDigester) Add(cw *certwatchv1.CertWatcher, emailConfiguration *properties.Properties, resources EmailResources, entry EmailDigestEntry) {
+	spec := cw.Spec.Actions.Email
+	window := defaultEmailDigestWindow
+	if spec.Digest != nil && spec.Digest.Window.Duration > 0 {
+		window = spec.Digest.Window.Duration
+	}
+	key := emailDigestKey(spec, emailConfiguration, resources)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.digests == nil {
+		d.digests = map[string]*emailDigest{}
+	}
+	digest, ok := d.digests[key]
+	if !ok {
+		digest = &emailDigest{
+			spec:               spec.DeepCopy(),
+			emailConfiguration: emailConfiguration,
+			resources:          resources,
+		}
+		d.digests[key] = digest
+		time.AfterFunc(window, func() { d.flush(key) })
+	}
+	for i := range digest.entries {
+		if digest.entries[i].CertWatcher == entry.CertWatcher {
+			digest.entries[i] = entry
+			return
+		}
+	}
+	digest.entries = append(digest.entries, entry)
+}
+
+// Has reports whether the change identified by the checksum is still buffered
+// (or being sent) for the given CertWatcher.
+func (d *EmailDigester) Has(name types.NamespacedName, checksum string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if c, ok := d.sending[name]; ok && c == checksum {
+		return true
+	}
+	for _, digest := range d.digests {
+		for _, entry := range digest.entries {
+			if entry.CertWatcher == name && entry.Checksum == checksum {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *EmailDigester) flush(key string) {
+	d.mutex.Lock()
+	digest, ok := d.digests[key]
+	delete(d.digests, key)
+	if ok {
+		if d.sending == nil {
+			d.sending = map[types.NamespacedName]string{}
+		}
+		for _, entry := range digest.entries {
+			d.sending[entry.CertWatcher] = entry.Checksum
+		}
+	}
+	ctx := d.ctx
+	d.mutex.Unlock()
+	if !ok {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	err := ProcessEmailDigest(ctx, d.Sender, digest.spec, digest.emailConfiguration, digest.resources, digest.entries)
+	if d.OnDelivered != nil {
+		d.OnDelivered(digest.entries, err)
+	}
+
+	d.mutex.Lock()
+	for _, entry := range digest.entries {
+		if d.sending[entry.CertWatcher] == entry.Checksum {
+			delete(d.sending, entry.CertWatcher)
+		}
+	}
+	d.mutex.Unlock()
+}
+
+// emailDigestKey identifies the digest a notification belongs to. Everything
+// taken from the spec and resources of the first CertWatcher to open the
+// digest is part of the key, so that notifications are only merged with
+// others sent the same way.
+func emailDigestKey(spec *certwatchv1.CertWatchActionEmail, emailConfiguration *properties.Properties, resources EmailResources) string {
+	hash := sha256.New()
+	for _, k := range emailConfiguration.Keys() {
+		hash.Write([]byte(k + "=" + emailConfiguration.GetString(k, "") + "\n"))
+	}
+	for _, v := range []string{spec.From, spec.To, spec.Cc, spec.Bcc} {
+		var addresses []string
+		for _, address := range strings.Split(v, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				addresses = append(addresses, strings.ToLower(address))
+			}
+		}
+		sort.Strings(addresses)
+		hash.Write([]byte(strings.Join(addresses, ",") + "\n"))
+	}
+	if spec.Digest != nil {
+		hash.Write([]byte(fmt.Sprintf("digest=%s,%t\n", spec.Digest.Subject, spec.Digest.OmitAttachments)))
+	}
+	if spec.Pgp != nil {
+		var fingerprints []string
+		for _, entity := range resources.PgpRecipients {
+			fingerprints = append(fingerprints, hex.EncodeToString(entity.PrimaryKey.Fingerprint[:]))
+		}
+		sort.Strings(fingerprints)
+		hash.Write([]byte(fmt.Sprintf("pgp=%s,%t,%s\n", defaultString(spec.Pgp.Mode, PgpModeAttachments), spec.Pgp.Armor, strings.Join(fingerprints, ","))))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ProcessEmailDigest sends one e-mail summarising all buffered changes.
+// Attachments are included unless the digest omits them, prefixed with the
+// CertWatcher namespace and name to keep them apart.
+func ProcessEmailDigest(ctx context.Context, sender *MailSender, spec *certwatchv1.CertWatchActionEmail, emailConfiguration *properties.Properties, resources EmailResources, entries []EmailDigestEntry) error {
+	var err error
+
+	pgpMode, err := emailPgpMode(spec, resources)
+	if err != nil {
+		return err
+	}
+
+	email, err := newEmail(spec, emailConfiguration)
+	if err != nil {
+		return err
+	}
+
+	subject := "Certificate changes"
+	if spec.Digest != nil && spec.Digest.Subject != "" {
+		subject = spec.Digest.Subject
+	}
+	email.SetSubject(subject)
+
+	var body strings.Builder
+	body.WriteString(fmt.Sprintf("%d certificate change(s) were detected:\n\n", len(entries)))
+	for _, entry := range entries {
+		body.WriteString(fmt.Sprintf("- CertWatcher %s: Secret %s changed at %s", entry.CertWatcher.String(), entry.Secret, entry.Time.UTC().Format(time.RFC3339)))
+		if entry.Subject != "" {
+			body.WriteString(fmt.Sprintf(" (%s)", entry.Subject))
+		}
+		body.WriteString("\n")
+	}
+	email.SetBody(mail.TextPlain, body.String())
+
+	if spec.Digest == nil || !spec.Digest.OmitAttachments {
+		for _, entry := range entries {
+			var names []string
+			for name := range entry.Attachments {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				data := entry.Attachments[name]
+				name = entry.CertWatcher.Namespace + "-" + entry.CertWatcher.Name + "-" + name
+				if pgpMode == PgpModeAttachments {
+					name, data, err = PgpEncryptData(name, data, resources.PgpRecipients, spec.Pgp.Armor)
+					if err != nil {
+						return err
+					}
+				}
+				email.Attach(&mail.File{Data: data, Name: name})
+				if email.Error != nil {
+					return email.Error
+				}
+			}
+		}
+	}
+
+	return sendEmail(ctx, sender, email, pgpMode, emailConfiguration, resources)
+}
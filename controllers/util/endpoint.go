@@ -0,0 +1,168 @@
+package util
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	apicorev1 "k8s.io/api/core/v1"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultEndpointPort is the port of remote endpoints, unless set.
+const DefaultEndpointPort = 443
+
+// DefaultEndpointTimeout limits connecting to a remote endpoint and
+// completing the TLS handshake, unless set.
+const DefaultEndpointTimeout = 10 * time.Second
+
+// EndpointStartTLSProtocols are the protocols supported to upgrade a plain
+// text connection to TLS.
+var EndpointStartTLSProtocols = []string{"smtp", "imap", "pop3", "ftp"}
+
+// EndpointOptions identify a remote TLS endpoint.
+type EndpointOptions struct {
+	Host string
+	Port int
+
+	// ServerName is sent in the handshake (SNI). Defaults to Host.
+	ServerName string
+
+	// StartTLS is one of EndpointStartTLSProtocols, or empty to perform the
+	// handshake right after connecting.
+	StartTLS string
+
+	Timeout time.Duration
+}
+
+// Address is host:port of the endpoint.
+func (o EndpointOptions) Address() string {
+	var port = o.Port
+	if port == 0 {
+		port = DefaultEndpointPort
+	}
+	return net.JoinHostPort(o.Host, strconv.Itoa(port))
+}
+
+// GetEndpointCertificates connects to the endpoint, performs a TLS handshake
+// and returns the certificate chain it presents, leaf first. The chain is not
+// verified: the point is to observe what the endpoint presents, whatever it
+// is.
+func GetEndpointCertificates(options EndpointOptions) ([]*x509.Certificate, error) {
+	var address = options.Address()
+	var timeout = options.Timeout
+	if timeout == 0 {
+		timeout = DefaultEndpointTimeout
+	}
+	var config = &tls.Config{
+		ServerName:         defaultString(options.ServerName, options.Host),
+		InsecureSkipVerify: true,
+	}
+
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %s", address, err.Error())
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	if options.StartTLS != "" {
+		if err = startTLS(conn, options.StartTLS); err != nil {
+			return nil, fmt.Errorf("%s STARTTLS with %s failed: %s", strings.ToUpper(options.StartTLS), address, err.Error())
+		}
+	}
+	tlsConn := tls.Client(conn, config)
+	if err = tlsConn.Handshake(); err != nil {
+		return nil, fmt.Errorf("TLS handshake with %s failed: %s", address, err.Error())
+	}
+	var state = tlsConn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("%s did not present any certificate", address)
+	}
+	return state.PeerCertificates, nil
+}
+
+// startTLS asks an SMTP, IMAP, POP3 or FTP server to upgrade the connection to
+// TLS, after reading its greeting.
+func startTLS(conn net.Conn, protocol string) error {
+	var reader = bufio.NewReader(conn)
+	var command, ok string
+	switch protocol {
+	case "smtp":
+		command, ok = "STARTTLS", "220"
+		if _, err := readResponse(reader, "220"); err != nil {
+			return err
+		}
+		if _, err := conn.Write([]byte("EHLO cert-watch\r\n")); err != nil {
+			return err
+		}
+		if _, err := readResponse(reader, "250"); err != nil {
+			return err
+		}
+	case "imap":
+		command, ok = "a001 STARTTLS", "a001 OK"
+		if _, err := readResponse(reader, "* OK"); err != nil {
+			return err
+		}
+	case "pop3":
+		command, ok = "STLS", "+OK"
+		if _, err := readResponse(reader, "+OK"); err != nil {
+			return err
+		}
+	case "ftp":
+		command, ok = "AUTH TLS", "234"
+		if _, err := readResponse(reader, "220"); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported protocol, expected one of %s", strings.Join(EndpointStartTLSProtocols, ", "))
+	}
+	if _, err := conn.Write([]byte(command + "\r\n")); err != nil {
+		return err
+	}
+	_, err := readResponse(reader, ok)
+	return err
+}
+
+// readResponse reads lines up to the final line of a response, which must
+// start with prefix. Untagged IMAP responses and SMTP or FTP continuation
+// lines are skipped.
+func readResponse(reader *bufio.Reader, prefix string) (string, error) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, prefix) {
+			// SMTP and FTP continuation lines look like "220-...".
+			if len(line) > len(prefix) && line[len(prefix)] == '-' {
+				continue
+			}
+			return line, nil
+		}
+		if strings.HasPrefix(line, "* ") || (len(line) > 3 && line[3] == '-') {
+			continue
+		}
+		return "", errors.New("unexpected response: " + line)
+	}
+}
+
+// EndpointAsSecret returns the certificate chain presented by an endpoint as a
+// Secret holding the PEM encoded certificates in tls.crt alone, in the same
+// way as ConfigMapAsSecret. The Secret is named after the endpoint address.
+func EndpointAsSecret(address string, namespace string, certificates []*x509.Certificate) *apicorev1.Secret {
+	return &apicorev1.Secret{
+		ObjectMeta: apimachineryv1.ObjectMeta{Name: address, Namespace: namespace},
+		Type:       apicorev1.SecretTypeTLS,
+		Data:       map[string][]byte{SecretCertificateKey: EncodeCertificates(certificates)},
+	}
+}
@@ -0,0 +1,154 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServerCertificate returns the certificate of a local TLS server, to be
+// presented by the fake greeters as well.
+func testServerCertificate(t *testing.T) tls.Certificate {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	return server.TLS.Certificates[0]
+}
+
+// fakeGreeter accepts one connection and plays a plain text exchange: the
+// first line is sent as the greeting, then every command expected from the
+// client is followed by the reply sent back. When the whole exchange is
+// played, the connection is upgraded to TLS.
+func fakeGreeter(t *testing.T, certificate tls.Certificate, greeting string, exchange ...string) EndpointOptions {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		var reader = bufio.NewReader(conn)
+		if _, err = conn.Write([]byte(greeting)); err != nil {
+			return
+		}
+		for i := 0; i+1 < len(exchange); i += 2 {
+			command, err := reader.ReadString('\n')
+			if err != nil || strings.TrimRight(command, "\r\n") != exchange[i] {
+				return
+			}
+			if _, err = conn.Write([]byte(exchange[i+1])); err != nil {
+				return
+			}
+		}
+		tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{certificate}})
+		_ = tlsConn.Handshake()
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return EndpointOptions{Host: host, Port: portNumber, Timeout: 5 * time.Second}
+}
+
+func TestGetEndpointCertificatesTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	certificates, err := GetEndpointCertificates(EndpointOptions{Host: host, Port: portNumber, ServerName: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(certificates[0].Raw, server.Certificate().Raw) {
+		t.Error("expected the certificate presented by the server")
+	}
+}
+
+func TestGetEndpointCertificatesStartTLS(t *testing.T) {
+	var certificate = testServerCertificate(t)
+	var tests = []struct {
+		protocol string
+		greeting string
+		exchange []string
+	}{
+		{"smtp", "220-mail.example.com ESMTP\r\n220 ready\r\n", []string{
+			"EHLO cert-watch", "250-mail.example.com\r\n250-STARTTLS\r\n250 SIZE 10240000\r\n",
+			"STARTTLS", "220 2.0.0 Ready to start TLS\r\n",
+		}},
+		{"imap", "* OK [CAPABILITY IMAP4rev1 STARTTLS] ready\r\n", []string{
+			"a001 STARTTLS", "* CAPABILITY IMAP4rev1\r\na001 OK Begin TLS negotiation now\r\n",
+		}},
+		{"pop3", "+OK POP3 ready\r\n", []string{
+			"STLS", "+OK Begin TLS negotiation\r\n",
+		}},
+		{"ftp", "220-Welcome\r\n220-to the\r\n220 FTP server\r\n", []string{
+			"AUTH TLS", "234 AUTH TLS successful\r\n",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.protocol, func(t *testing.T) {
+			options := fakeGreeter(t, certificate, test.greeting, test.exchange...)
+			options.StartTLS = test.protocol
+			certificates, err := GetEndpointCertificates(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(certificates[0].Raw, certificate.Certificate[0]) {
+				t.Error("expected the certificate presented by the server")
+			}
+		})
+	}
+}
+
+func TestGetEndpointCertificatesStartTLSRefused(t *testing.T) {
+	var certificate = testServerCertificate(t)
+	options := fakeGreeter(t, certificate, "220 mail.example.com ESMTP\r\n",
+		"EHLO cert-watch", "250-mail.example.com\r\n250 SIZE 10240000\r\n",
+		"STARTTLS", "454 4.7.0 TLS not available\r\n",
+	)
+	options.StartTLS = "smtp"
+	_, err := GetEndpointCertificates(options)
+	if err == nil || !strings.Contains(err.Error(), "454 4.7.0 TLS not available") {
+		t.Errorf("expected the error reply, got %v", err)
+	}
+
+	options.StartTLS = "gopher"
+	if _, err = GetEndpointCertificates(options); err == nil || !strings.Contains(err.Error(), "unsupported protocol") {
+		t.Errorf("expected an unsupported protocol error, got %v", err)
+	}
+}
+
+func TestReadResponse(t *testing.T) {
+	var tests = []struct {
+		response string
+		prefix   string
+		line     string
+		err      bool
+	}{
+		{"250-first\r\n250-second\r\n250 last\r\n", "250", "250 last", false},
+		{"* CAPABILITY IMAP4rev1\r\n* OK still\r\na001 OK done\r\n", "a001 OK", "a001 OK done", false},
+		{"+OK ready\r\n", "+OK", "+OK ready", false},
+		{"220-hello\r\n554 go away\r\n", "220", "", true},
+		{"a001 BAD unknown command\r\n", "a001 OK", "", true},
+		{"250-truncated\r\n", "250", "", true},
+	}
+	for _, test := range tests {
+		line, err := readResponse(bufio.NewReader(strings.NewReader(test.response)), test.prefix)
+		if (err != nil) != test.err {
+			t.Errorf("%q: unexpected error %v", test.response, err)
+		}
+		if line != test.line {
+			t.Errorf("%q: expected %q, got %q", test.response, test.line, line)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package util
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	apicorev1 "k8s.io/api/core/v1"
+)
+
+// FilenameTemplateData is the data available to file name templates, such as
+// `{{ .CommonName }}-{{ .NotAfter.Format "2006-01-02" }}`. Certificate values
+// are made safe for file names: `*` becomes `wildcard` and any character
+// other than letters, digits, dots, dashes and underscores becomes `_`, as do
+// the dots of values made of dots only.
+type FilenameTemplateData struct {
+	// CommonName of the leaf certificate subject.
+	CommonName string
+
+	// SAN is the first subject alternative name of the leaf certificate: a DNS
+	// name or, if there are none, an IP address, e-mail address or URI.
+	SAN string
+
+	// Serial number of the leaf certificate, in uppercase hexadecimal.
+	Serial string
+
+	// NotBefore and NotAfter are the validity dates of the leaf certificate.
+	NotBefore time.Time
+	NotAfter  time.Time
+
+	// Name and Namespace of the CertWatcher.
+	Name      string
+	Namespace string
+
+	// SecretName and SecretNamespace of the watched Secret.
+	SecretName      string
+	SecretNamespace string
+
+	// Prefix is the rendered filenames prefix, for file references in
+	// actions. Not available to the filenames prefix itself.
+	Prefix string
+}
+
+// IsFilenameTemplate reports whether the text has template actions.
+func IsFilenameTemplate(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+// NewFilenameTemplateData collects template data from the CertWatcher and
+// from the leaf certificate in the Secret, that is, the one matching tls.key
+// or, without a usable key, the first in tls.crt.
+func NewFilenameTemplateData(cw *certwatchv1.CertWatcher, secret *apicorev1.Secret) (FilenameTemplateData, error) {
+	var data = FilenameTemplateData{
+		Name:            cw.Name,
+		Namespace:       cw.Namespace,
+		SecretName:      secret.Name,
+		SecretNamespace: secret.Namespace,
+	}
+	tlsCrt, ok := secret.Data["tls.crt"]
+	if !ok {
+		return data, fmt.Errorf("secret %s/%s does not have value for tls.crt", secret.Namespace, secret.Name)
+	}
+	certs, err := ParseCertificates(tlsCrt)
+	if err != nil {
+		return data, fmt.Errorf("cannot parse tls.crt from secret %s/%s: %s", secret.Namespace, secret.Name, err.Error())
+	}
+	var leaf = certs[0]
+	if key, err := ParsePrivateKey(secret.Data["tls.key"]); err == nil {
+		for _, cert := range certs {
+			if KeyMatchesCertificate(key, cert) {
+				leaf = cert
+				break
+			}
+		}
+	}
+
+	data.CommonName = filenameSafe(leaf.Subject.CommonName)
+	data.SAN = filenameSafe(firstSubjectAlternativeName(leaf))
+	data.Serial = fmt.Sprintf("%X", leaf.SerialNumber)
+	data.NotBefore = leaf.NotBefore
+	data.NotAfter = leaf.NotAfter
+	return data, nil
+}
+
+// RenderFilename renders a file name template. Text without template actions
+// is returned as is. Referencing unknown fields is an error, and so is an
+// empty result.
+func RenderFilename(text string, data FilenameTemplateData) (string, error) {
+	if !IsFilenameTemplate(text) {
+		return text, nil
+	}
+	tmpl, err := template.New("filename").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid file name template %q: %s", text, err.Error())
+	}
+	var buffer bytes.Buffer
+	if err = tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("cannot render file name template %q: %s", text, err.Error())
+	}
+	if buffer.Len() == 0 {
+		return "", fmt.Errorf("file name template %q renders an empty name", text)
+	}
+	return buffer.String(), nil
+}
+
+// RenderFilenamesPrefix renders the filenames prefix template, which, unlike
+// other file names, must not contain directories.
+func RenderFilenamesPrefix(text string, data FilenameTemplateData) (string, error) {
+	prefix, err := RenderFilename(text, data)
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(prefix, "/\\") {
+		return "", errors.New("filenamesPrefix must not contain directories: " + prefix)
+	}
+	return prefix, nil
+}
+
+func firstSubjectAlternativeName(cert *x509.Certificate) string {
+	switch {
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.IPAddresses) > 0:
+		return cert.IPAddresses[0].String()
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	}
+	return ""
+}
+
+// filenameSafe makes a certificate value safe for file names. Values made of
+// dots only, such as `..`, have them replaced as well, so they never refer to
+// a directory.
+func filenameSafe(value string) string {
+	value = strings.ReplaceAll(value, "*", "wildcard")
+	value = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, value)
+	if strings.Trim(value, ".") == "" {
+		return strings.Repeat("_", len(value))
+	}
+	return value
+}
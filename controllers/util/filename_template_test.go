@@ -0,0 +1,118 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	apicorev1 "k8s.io/api/core/v1"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+)
+
+// filenameTestSecret returns a Secret with a self-signed certificate for the
+// common name and DNS names.
+func filenameTestSecret(t *testing.T, commonName string, dnsNames ...string) *apicorev1.Secret {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = &x509.Certificate{
+		SerialNumber: big.NewInt(0xC0FFEE),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &apicorev1.Secret{
+		ObjectMeta: apimachineryv1.ObjectMeta{Namespace: "default", Name: "example-tls"},
+		Data:       map[string][]byte{"tls.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})},
+	}
+}
+
+func TestFilenameSafe(t *testing.T) {
+	var tests = map[string]string{
+		"example.com":          "example.com",
+		"*.example.com":        "wildcard.example.com",
+		"../../etc/passwd":     ".._.._etc_passwd",
+		"..":                   "__",
+		".":                    "_",
+		"a/b\\c d":             "a_b_c_d",
+		"my_host-01.example":   "my_host-01.example",
+		"user@example.com":     "user_example.com",
+		"https://example.com/": "https___example.com_",
+		"":                     "",
+	}
+	for value, expected := range tests {
+		if safe := filenameSafe(value); safe != expected {
+			t.Errorf("%q: expected %q, got %q", value, expected, safe)
+		}
+	}
+}
+
+func TestRenderFilename(t *testing.T) {
+	var cw = &certwatchv1.CertWatcher{ObjectMeta: apimachineryv1.ObjectMeta{Namespace: "web", Name: "example"}}
+	var tests = []struct {
+		commonName string
+		dnsNames   []string
+		text       string
+		expected   string
+	}{
+		{"*.example.com", nil, `{{ .CommonName }}-{{ .NotAfter.Format "2006-01-02" }}.zip`, "wildcard.example.com-2025-12-31.zip"},
+		{"example.com", []string{"*.example.com"}, "{{ .SAN }}", "wildcard.example.com"},
+		{"../../etc/passwd", nil, "{{ .CommonName }}.crt", ".._.._etc_passwd.crt"},
+		{"..", []string{".."}, "{{ .CommonName }}", "__"},
+		{"example.com", []string{"a/../b.example.com"}, "{{ .SAN }}", "a_.._b.example.com"},
+		{"example.com", nil, "{{ .Namespace }}-{{ .Name }}-{{ .Serial }}", "web-example-C0FFEE"},
+		{"example.com", nil, "static.crt", "static.crt"},
+	}
+	for _, test := range tests {
+		data, err := NewFilenameTemplateData(cw, filenameTestSecret(t, test.commonName, test.dnsNames...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		filename, err := RenderFilename(test.text, data)
+		if err != nil {
+			t.Errorf("%s: %s", test.text, err.Error())
+			continue
+		}
+		if filename != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.text, test.expected, filename)
+		}
+		if strings.ContainsAny(filename, "/\\") {
+			t.Errorf("%s: expected no directories in %q", test.text, filename)
+		}
+	}
+
+	var data = FilenameTemplateData{CommonName: "example.com"}
+	for _, text := range []string{`{{ "" }}`, `{{ if false }}x{{ end }}`, "{{ .Unknown }}", "{{ .CommonName"} {
+		if filename, err := RenderFilename(text, data); err == nil {
+			t.Errorf("%s: expected an error, got %q", text, filename)
+		}
+	}
+}
+
+func TestRenderFilenamesPrefix(t *testing.T) {
+	var data = FilenameTemplateData{CommonName: "example.com", Namespace: "web"}
+	prefix, err := RenderFilenamesPrefix("{{ .CommonName }}", data)
+	if err != nil || prefix != "example.com" {
+		t.Errorf("expected example.com, got %q (%v)", prefix, err)
+	}
+	for _, text := range []string{"certs/{{ .CommonName }}", `{{ .Namespace }}\{{ .CommonName }}`, "../tls", `{{ "" }}`} {
+		if prefix, err := RenderFilenamesPrefix(text, data); err == nil {
+			t.Errorf("%s: expected an error, got %q", text, prefix)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	keystore "github.com/pavel-v-chernykh/keystore-go/v4"
+)
+
+// DefaultKeystorePassword is the password of Java keystores and truststores
+// when none is provided, the same default used by Java itself.
+const DefaultKeystorePassword = "changeit"
+
+// KeystoreOptions configures Java keystore and truststore files.
+type KeystoreOptions struct {
+	// Alias of the private key entry in the keystore. Truststore entries are
+	// named `<alias>-ca-<n>`. Defaults to the filenames prefix.
+	Alias string
+
+	// Password of the keystore and its private key entry. Defaults to
+	// DefaultKeystorePassword. JKS requires at least 6 characters.
+	Password string
+
+	// TruststorePassword of the truststore files. Defaults to Password.
+	TruststorePassword string
+}
+
+// TrustedCertificates returns the certificates a truststore for the given
+// chain should contain: the intermediates and the root, taken from ca.crt when
+// tls.crt does not include it, or the leaf itself when there are none.
+func TrustedCertificates(chain *CertificateChain) []*x509.Certificate {
+	var certs = append([]*x509.Certificate{}, chain.Intermediates...)
+	if chain.Root != nil {
+		certs = append(certs, chain.Root)
+	}
+	if len(certs) == 0 {
+		return []*x509.Certificate{chain.Leaf}
+	}
+	return certs
+}
+
+// EncodeJKS creates a Java keystore (JKS) with the private key and its
+// certificate chain under the given alias.
+func EncodeJKS(key crypto.Signer, certs []*x509.Certificate, alias string, password string) ([]byte, error) {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	entry := keystore.PrivateKeyEntry{
+		CreationTime: time.Now(),
+		PrivateKey:   pkcs8,
+	}
+	for _, cert := range certs {
+		entry.CertificateChain = append(entry.CertificateChain, keystore.Certificate{Type: "X509", Content: cert.Raw})
+	}
+
+	ks := keystore.New(keystore.WithCaseExactAliases())
+	if err = ks.SetPrivateKeyEntry(alias, entry, []byte(password)); err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	if err = ks.Store(&buffer, []byte(password)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// EncodeJKSTruststore creates a Java keystore (JKS) with the certificates as
+// trusted certificate entries, named `<alias>-ca-<n>`.
+func EncodeJKSTruststore(certs []*x509.Certificate, alias string, password string) ([]byte, error) {
+	ks := keystore.New(keystore.WithCaseExactAliases(), keystore.WithOrderedAliases())
+	for i, cert := range certs {
+		entry := keystore.TrustedCertificateEntry{
+			CreationTime: time.Now(),
+			Certificate:  keystore.Certificate{Type: "X509", Content: cert.Raw},
+		}
+		if err := ks.SetTrustedCertificateEntry(fmt.Sprintf("%s-ca-%d", alias, i+1), entry); err != nil {
+			return nil, err
+		}
+	}
+	var buffer bytes.Buffer
+	if err := ks.Store(&buffer, []byte(password)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
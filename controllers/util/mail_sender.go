@@ -0,0 +1,418 @@
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/magiconair/properties"
+	mail "github.com/xhit/go-simple-mail/v2"
+	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
+)
+
+// MailSender delivers e-mail messages for all CertWatchers through a shared
+// pool of SMTP connections. Messages are queued per SMTP server, identified by
+// host, port and username, and delivered by up to `pool.maxConnections`
+// workers, each one keeping its connection open
+// (keep-alive) until it has been idle for `pool.idleTimeout`. Deliveries are
+// rate limited per server and transient failures (4xx replies and dropped
+// connections) are retried with exponential backoff.
+//
+// Pool, rate limiting and retry settings are read from the email
+// configuration of the first message sent to a given server:
+//
+// - pool.maxConnections     (default 2)
+// - pool.idleTimeout        (default 30s)
+// - pool.queueSize          (default 100)
+// - rateLimit.perSecond     (default 0, unlimited)
+// - rateLimit.burst         (default 1)
+// - retry.maxAttempts       (default 5)
+// - retry.initialDelay      (default 5s)
+//
+// Pools left idle are removed once their rate limit has been replenished.
+//
+// MailSender implements manager.Runnable, so it can be added to the
+// controller manager, which closes all pooled connections on shutdown.
+type MailSender struct {
+	servers  map[string]*mailServer
+	mutex    sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMailSender creates an empty MailSender. Server pools are created on
+// demand, as messages are sent.
+func NewMailSender() *MailSender {
+	return &MailSender{
+		servers: map[string]*mailServer{},
+		stop:    make(chan struct{}),
+	}
+}
+
+// mailServerSweepInterval is how often idle pools are looked for.
+var mailServerSweepInterval = time.Minute
+
+// Start removes idle pools until the context is done and then stops all
+// workers, closing their connections.
+func (s *MailSender) Start(ctx context.Context) error {
+	ticker := time.NewTicker(mailServerSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.stopOnce.Do(func() { close(s.stop) })
+			return nil
+		case now := <-ticker.C:
+			s.removeIdleServers(now)
+		}
+	}
+}
+
+// Send queues a fully composed message for delivery through the SMTP server
+// described in the email configuration and waits for the result. OAuth2
+// credentials are only used when the server uses XOAUTH2 authentication.
+func (s *MailSender) Send(ctx context.Context, emailConfiguration *properties.Properties, oauth2Credentials map[string][]byte, from string, recipients []string, msg string) error {
+	if from == "" {
+		return errors.New("mail error: no From address specified")
+	}
+	if len(recipients) < 1 {
+		return errors.New("mail error: no recipient specified")
+	}
+
+	server := s.server(emailConfiguration)
+	defer func() {
+		server.mutex.Lock()
+		server.senders--
+		server.mutex.Unlock()
+	}()
+	job := &mailJob{
+		ctx:                ctx,
+		emailConfiguration: emailConfiguration,
+		oauth2Credentials:  oauth2Credentials,
+		settings:           mailConnectionSettings(emailConfiguration, oauth2Credentials),
+		from:               from,
+		recipients:         recipients,
+		msg:                msg,
+		result:             make(chan error, 1),
+	}
+	select {
+	case server.queue <- job:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.stop:
+		return errors.New("mail sender stopped")
+	}
+
+	server.mutex.Lock()
+	if server.workers < server.maxConnections {
+		server.workers++
+		go s.worker(server)
+	}
+	server.mutex.Unlock()
+
+	select {
+	case err := <-job.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.stop:
+		return errors.New("mail sender stopped")
+	}
+}
+
+// mailJob is a message waiting for delivery. Messages for the same server may
+// come with different configurations and credentials, which are used to
+// connect when the current connection was opened with other settings.
+type mailJob struct {
+	ctx                context.Context
+	emailConfiguration *properties.Properties
+	oauth2Credentials  map[string][]byte
+	settings           string
+	from               string
+	recipients         []string
+	msg                string
+	result             chan error
+}
+
+type mailServer struct {
+	queue          chan *mailJob
+	limiter        *rate.Limiter
+	refill         time.Duration
+	maxConnections int
+	idleTimeout    time.Duration
+	maxAttempts    int
+	initialDelay   time.Duration
+	workers        int
+	senders        int
+	lastUsed       time.Time // when the last worker exited
+	mutex          sync.Mutex
+}
+
+// mailServerKey identifies the pool of an SMTP server. Limits of mail
+// providers apply to the account on the server, regardless of the other
+// settings.
+func mailServerKey(emailConfiguration *properties.Properties) string {
+	return net.JoinHostPort(emailConfiguration.GetString("host", ""), strconv.Itoa(emailConfiguration.GetInt("port", 0))) + "/" + emailConfiguration.GetString("username", "")
+}
+
+// server returns the pool for the SMTP server described in the email
+// configuration, creating it if necessary, and counts the caller as one of
+// its senders.
+func (s *MailSender) server(emailConfiguration *properties.Properties) *mailServer {
+	key := mailServerKey(emailConfiguration)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	server, ok := s.servers[key]
+	if !ok {
+		limit := rate.Inf
+		if perSecond := emailConfiguration.GetFloat64("rateLimit.perSecond", 0); perSecond > 0 {
+			limit = rate.Limit(perSecond)
+		}
+		server = &mailServer{
+			queue:          make(chan *mailJob, emailConfiguration.GetInt("pool.queueSize", 100)),
+			limiter:        rate.NewLimiter(limit, emailConfiguration.GetInt("rateLimit.burst", 1)),
+			maxConnections: emailConfiguration.GetInt("pool.maxConnections", 2),
+			idleTimeout:    emailConfiguration.GetParsedDuration("pool.idleTimeout", 30*time.Second),
+			maxAttempts:    emailConfiguration.GetInt("retry.maxAttempts", 5),
+			initialDelay:   emailConfiguration.GetParsedDuration("retry.initialDelay", 5*time.Second),
+		}
+		if limit != rate.Inf {
+			server.refill = time.Duration(float64(server.limiter.Burst()) / float64(limit) * float64(time.Second))
+		}
+		if server.maxConnections < 1 {
+			server.maxConnections = 1
+		}
+		s.servers[key] = server
+	}
+	server.mutex.Lock()
+	server.senders++
+	server.mutex.Unlock()
+	return server
+}
+
+// removeIdleServers removes the pools without messages, workers or senders,
+// once their rate limiter has been replenished, so starting over with a new
+// pool does not allow more messages than the limit.
+func (s *MailSender) removeIdleServers(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, server := range s.servers {
+		server.mutex.Lock()
+		if server.senders == 0 && server.workers == 0 && len(server.queue) == 0 && now.Sub(server.lastUsed) >= server.refill {
+			delete(s.servers, key)
+		}
+		server.mutex.Unlock()
+	}
+}
+
+// mailConnectionSettings identifies the configuration and credentials a
+// connection is opened with.
+func mailConnectionSettings(emailConfiguration *properties.Properties, oauth2Credentials map[string][]byte) string {
+	hash := sha256.New()
+	for _, k := range emailConfiguration.Keys() {
+		hash.Write([]byte(k + "=" + emailConfiguration.GetString(k, "") + "\n"))
+	}
+	var credentialKeys []string
+	for k := range oauth2Credentials {
+		credentialKeys = append(credentialKeys, k)
+	}
+	sort.Strings(credentialKeys)
+	for _, k := range credentialKeys {
+		hash.Write([]byte(k + "="))
+		hash.Write(oauth2Credentials[k])
+		hash.Write([]byte("\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// worker delivers queued messages, reusing its connection until it is idle
+// for longer than the configured timeout. Workers exit when idle, so pools
+// that are no longer used do not keep any connections open.
+func (s *MailSender) worker(server *mailServer) {
+	var conn mailConnection
+	var settings string
+	defer func() {
+		if conn != nil {
+			conn.close()
+		}
+	}()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case job := <-server.queue:
+			// The caller no longer waits for the result, and would send the
+			// message again when retrying.
+			if err := job.ctx.Err(); err != nil {
+				job.result <- err
+				continue
+			}
+			if conn != nil && settings != job.settings {
+				conn.close()
+				conn = nil
+			}
+			settings = job.settings
+			job.result <- s.deliver(server, &conn, job)
+		case <-time.After(server.idleTimeout):
+			server.mutex.Lock()
+			if len(server.queue) > 0 {
+				server.mutex.Unlock()
+				continue
+			}
+			server.workers--
+			server.lastUsed = time.Now()
+			server.mutex.Unlock()
+			return
+		}
+	}
+}
+
+// deliver sends one message, connecting when necessary and retrying transient
+// failures with exponential backoff.
+func (s *MailSender) deliver(server *mailServer, conn *mailConnection, job *mailJob) error {
+	var err error
+	delay := server.initialDelay
+	for attempt := 1; ; attempt++ {
+		if err = server.limiter.Wait(job.ctx); err != nil {
+			return err
+		}
+		if *conn == nil {
+			*conn, err = connectMailServer(job.emailConfiguration, job.oauth2Credentials)
+			if err != nil && !isTransientConnectError(err) {
+				return err
+			}
+		}
+		if err == nil {
+			err = (*conn).send(job.from, job.recipients, job.msg)
+			if err == nil {
+				return nil
+			}
+			// Drop the connection after any failure, so the next attempt (or the
+			// next message) starts from a clean session.
+			(*conn).close()
+			*conn = nil
+			if !isTransientMailError(err) {
+				return err
+			}
+		}
+		if attempt >= server.maxAttempts {
+			return err
+		}
+		select {
+		case <-s.stop:
+			return err
+		case <-job.ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// isTransientMailError reports whether a delivery failure is worth retrying:
+// 4xx SMTP replies and errors that are not SMTP replies at all, such as a
+// connection closed by the server while idle.
+func isTransientMailError(err error) bool {
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 400 && smtpErr.Code < 500
+	}
+	return true
+}
+
+// isTransientConnectError reports whether a failure to connect or
+// authenticate is worth retrying: 4xx SMTP replies, 5xx or 429 replies from
+// the OAuth2 token endpoint and network errors. Rejected credentials, TLS
+// failures and configuration errors are not retried.
+func isTransientConnectError(err error) bool {
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 400 && smtpErr.Code < 500
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return retrieveErr.Response.StatusCode >= 500 || retrieveErr.Response.StatusCode == http.StatusTooManyRequests
+	}
+	if strings.Contains(err.Error(), "x509:") || strings.Contains(err.Error(), "tls:") {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// go-simple-mail reports dial failures as plain errors.
+	return strings.HasPrefix(err.Error(), "Mail Error on dialing") || strings.HasPrefix(err.Error(), "Mail Error on smtp dial")
+}
+
+// mailConnection is an open session with an SMTP server, already
+// authenticated and ready to deliver messages.
+type mailConnection interface {
+	send(from string, recipients []string, msg string) error
+	close()
+}
+
+func connectMailServer(emailConfiguration *properties.Properties, oauth2Credentials map[string][]byte) (mailConnection, error) {
+	if emailConfiguration.GetString("authentication", "") == "xoauth2" {
+		token, err := OAuth2Token(emailConfiguration, oauth2Credentials)
+		if err != nil {
+			return nil, err
+		}
+		c, err := dialXOAuth2(emailConfiguration, token)
+		if err != nil {
+			return nil, err
+		}
+		return &xoauth2Connection{client: c}, nil
+	}
+
+	server := mail.NewSMTPClient()
+	server.Host = emailConfiguration.MustGetString("host")
+	server.Port = emailConfiguration.MustGetInt("port")
+	server.Username = emailConfiguration.GetString("username", "")
+	server.Password = emailConfiguration.GetString("password", "")
+	server.KeepAlive = true
+
+	sEncryptionType := emailConfiguration.GetString("encryption", "")
+	switch sEncryptionType {
+	case "SSL":
+		server.Encryption = mail.EncryptionSSL
+	case "TLS":
+		server.Encryption = mail.EncryptionTLS
+	case "SSLTLS":
+		server.Encryption = mail.EncryptionSSLTLS
+	case "STARTTLS":
+		server.Encryption = mail.EncryptionSTARTTLS
+	default:
+		server.Encryption = mail.EncryptionNone
+	}
+
+	smtpClient, err := server.Connect()
+	if err != nil {
+		return nil, err
+	}
+	return &simpleMailConnection{client: smtpClient}, nil
+}
+
+type simpleMailConnection struct {
+	client *mail.SMTPClient
+}
+
+func (c *simpleMailConnection) send(from string, recipients []string, msg string) error {
+	return mail.SendMessage(from, recipients, msg, c.client)
+}
+
+func (c *simpleMailConnection) close() {
+	_ = c.client.Quit()
+	_ = c.client.Close()
+}
@@ -0,0 +1,107 @@
+package util
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+)
+
+func mailConfiguration(host string, port int, username string, extra ...string) *properties.Properties {
+	var p = properties.NewProperties()
+	p.MustSet("host", host)
+	p.MustSet("port", strconv.Itoa(port))
+	p.MustSet("username", username)
+	for i := 0; i+1 < len(extra); i += 2 {
+		p.MustSet(extra[i], extra[i+1])
+	}
+	return p
+}
+
+func TestMailSenderServerKey(t *testing.T) {
+	var s = NewMailSender()
+	var server = s.server(mailConfiguration("mail.example.com", 587, "user", "from", "a@example.com", "rateLimit.perSecond", "1"))
+	// Other settings and credentials do not matter, so the rate limit applies
+	// to every CertWatcher using the same account.
+	if other := s.server(mailConfiguration("mail.example.com", 587, "user", "from", "b@example.com", "password", "rotated")); other != server {
+		t.Error("expected the same pool for the same host, port and username")
+	}
+	for _, config := range []*properties.Properties{
+		mailConfiguration("mail.example.com", 587, "other"),
+		mailConfiguration("mail.example.com", 465, "user"),
+		mailConfiguration("smtp.example.com", 587, "user"),
+	} {
+		if s.server(config) == server {
+			t.Errorf("expected another pool for %s", mailServerKey(config))
+		}
+	}
+	if len(s.servers) != 4 {
+		t.Errorf("expected 4 pools, got %d", len(s.servers))
+	}
+	if server.senders != 2 {
+		t.Errorf("expected 2 senders, got %d", server.senders)
+	}
+}
+
+func TestMailSenderRemoveIdleServers(t *testing.T) {
+	var s = NewMailSender()
+	var now = time.Now()
+	limited := s.server(mailConfiguration("limited.example.com", 587, "user", "rateLimit.perSecond", "0.5", "rateLimit.burst", "2"))
+	unlimited := s.server(mailConfiguration("unlimited.example.com", 587, "user"))
+	busy := s.server(mailConfiguration("busy.example.com", 587, "user"))
+	for _, server := range []*mailServer{limited, unlimited} {
+		server.senders--
+		server.lastUsed = now
+	}
+
+	s.removeIdleServers(now)
+	if len(s.servers) != 2 || s.servers[mailServerKey(mailConfiguration("unlimited.example.com", 587, "user"))] != nil {
+		t.Errorf("expected only the unlimited pool to be removed, got %v", s.servers)
+	}
+	// Two messages at 0.5 per second take 4 seconds to be replenished.
+	s.removeIdleServers(now.Add(3 * time.Second))
+	if len(s.servers) != 2 {
+		t.Errorf("expected the limited pool to be kept until replenished, got %v", s.servers)
+	}
+	s.removeIdleServers(now.Add(4 * time.Second))
+	if len(s.servers) != 1 || s.servers[mailServerKey(mailConfiguration("busy.example.com", 587, "user"))] != busy {
+		t.Errorf("expected only the pool with a sender to be kept, got %v", s.servers)
+	}
+}
+
+func TestMailSenderCancelledJob(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	var port = listener.Addr().(*net.TCPAddr).Port
+
+	var s = NewMailSender()
+	defer s.stopOnce.Do(func() { close(s.stop) })
+	var config = mailConfiguration("127.0.0.1", port, "user")
+	var server = s.server(config)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var job = &mailJob{ctx: ctx, emailConfiguration: config, from: "a@example.com", recipients: []string{"b@example.com"}, msg: "Subject: test\r\n\r\ntest", result: make(chan error, 1)}
+	server.queue <- job
+	server.workers++
+	go s.worker(server)
+
+	select {
+	case err = <-job.result:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the job to be dropped")
+	}
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	_ = listener.(*net.TCPListener).SetDeadline(time.Now().Add(100 * time.Millisecond))
+	if conn, err := listener.Accept(); err == nil {
+		conn.Close()
+		t.Error("expected no connection to the server")
+	}
+}
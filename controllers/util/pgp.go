@@ -0,0 +1,172 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	// Keys without hash preferences fall back to RIPEMD160, which openpgp
+	// requires to be available even for unsigned messages.
+	_ "golang.org/x/crypto/ripemd160"
+)
+
+const (
+	PgpModeAttachments = "attachments"
+	PgpModeBody        = "body"
+)
+
+// ParsePgpPublicKeys reads every armored public key found in the given values.
+// Each value may hold more than one key. An error is returned if any value
+// cannot be parsed or if no keys are found at all.
+func ParsePgpPublicKeys(values map[string][]byte) (openpgp.EntityList, error) {
+	var entities openpgp.EntityList
+	for name, value := range values {
+		keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(value))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read PGP public key %s: %s", name, err.Error())
+		}
+		entities = append(entities, keyring...)
+	}
+	if len(entities) == 0 {
+		return nil, errors.New("no PGP public keys found")
+	}
+	return entities, nil
+}
+
+// PgpEncryptFile encrypts a file from the temporary workspace directory to all
+// recipients, saving the result in the same directory. The encrypted file is
+// named after the original one, with a `.asc` extension when armored or `.pgp`
+// otherwise. The name of the new file, relative to the workspace directory, is
+// returned.
+func PgpEncryptFile(certFilesDir string, name string, recipients openpgp.EntityList, armored bool) (string, error) {
+	data, err := os.ReadFile(filepath.Join(certFilesDir, name))
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s for PGP encryption: %s", name, err.Error())
+	}
+	encryptedName, encrypted, err := PgpEncryptData(name, data, recipients, armored)
+	if err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(filepath.Join(certFilesDir, encryptedName), encrypted, 0600); err != nil {
+		return "", fmt.Errorf("unable to write %s: %s", encryptedName, err.Error())
+	}
+	return encryptedName, nil
+}
+
+// PgpEncryptData encrypts the contents of a named file to all recipients,
+// returning the name of the encrypted file (see PgpEncryptFile) and its
+// contents.
+func PgpEncryptData(name string, data []byte, recipients openpgp.EntityList, armored bool) (string, []byte, error) {
+	encryptedName := name + ".pgp"
+	if armored {
+		encryptedName = name + ".asc"
+	}
+	var buf bytes.Buffer
+	if err := pgpEncrypt(&buf, data, name, recipients, armored); err != nil {
+		return "", nil, fmt.Errorf("unable to PGP encrypt %s: %s", name, err.Error())
+	}
+	return encryptedName, buf.Bytes(), nil
+}
+
+// PgpMimeMessage converts a fully composed RFC822 message into a PGP/MIME
+// (RFC 3156) message. The original content headers and body are encrypted as
+// a whole and wrapped in a multipart/encrypted body. All other headers (From,
+// To, Subject, etc.) are kept untouched.
+func PgpMimeMessage(message string, recipients openpgp.EntityList) (string, error) {
+	parsed, err := mail.ReadMessage(strings.NewReader(message))
+	if err != nil {
+		return "", fmt.Errorf("unable to parse message for PGP encryption: %s", err.Error())
+	}
+	body, err := io.ReadAll(parsed.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to read message body for PGP encryption: %s", err.Error())
+	}
+
+	var inner bytes.Buffer
+	for _, h := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+		if v := parsed.Header.Get(h); v != "" {
+			inner.WriteString(h + ": " + v + "\r\n")
+		}
+	}
+	inner.WriteString("\r\n")
+	inner.Write(body)
+
+	var encrypted bytes.Buffer
+	if err = pgpEncrypt(&encrypted, inner.Bytes(), "", recipients, true); err != nil {
+		return "", fmt.Errorf("unable to PGP encrypt message: %s", err.Error())
+	}
+
+	boundary, err := RandoHash(30)
+	if err != nil {
+		return "", err
+	}
+
+	var headers []string
+	for h := range parsed.Header {
+		switch h {
+		case "Content-Type", "Content-Transfer-Encoding", "Mime-Version":
+			continue
+		}
+		headers = append(headers, h)
+	}
+	sort.Strings(headers)
+
+	var out strings.Builder
+	for _, h := range headers {
+		for _, v := range parsed.Header[h] {
+			out.WriteString(h + ": " + v + "\r\n")
+		}
+	}
+	out.WriteString("MIME-Version: 1.0\r\n")
+	out.WriteString("Content-Type: multipart/encrypted; protocol=\"application/pgp-encrypted\"; boundary=\"" + boundary + "\"\r\n")
+	out.WriteString("\r\n")
+	out.WriteString("This is an OpenPGP/MIME encrypted message (RFC 3156).\r\n")
+	out.WriteString("--" + boundary + "\r\n")
+	out.WriteString("Content-Type: application/pgp-encrypted\r\n")
+	out.WriteString("Content-Description: PGP/MIME version identification\r\n")
+	out.WriteString("\r\n")
+	out.WriteString("Version: 1\r\n")
+	out.WriteString("\r\n")
+	out.WriteString("--" + boundary + "\r\n")
+	out.WriteString("Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n")
+	out.WriteString("Content-Description: OpenPGP encrypted message\r\n")
+	out.WriteString("Content-Disposition: inline; filename=\"encrypted.asc\"\r\n")
+	out.WriteString("\r\n")
+	out.WriteString(strings.ReplaceAll(encrypted.String(), "\n", "\r\n"))
+	out.WriteString("\r\n--" + boundary + "--\r\n")
+	return out.String(), nil
+}
+
+func pgpEncrypt(w io.Writer, data []byte, filename string, recipients openpgp.EntityList, armored bool) error {
+	var err error
+	var armorWriter io.WriteCloser
+	if armored {
+		armorWriter, err = armor.Encode(w, "PGP MESSAGE", nil)
+		if err != nil {
+			return err
+		}
+		w = armorWriter
+	}
+	plaintext, err := openpgp.Encrypt(w, recipients, nil, &openpgp.FileHints{IsBinary: true, FileName: filename}, nil)
+	if err != nil {
+		return err
+	}
+	if _, err = plaintext.Write(data); err != nil {
+		return err
+	}
+	if err = plaintext.Close(); err != nil {
+		return err
+	}
+	if armorWriter != nil {
+		return armorWriter.Close()
+	}
+	return nil
+}
@@ -0,0 +1,551 @@
+package util
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PKCS#12 encryption profiles.
+//
+//   - legacy: certificates encrypted with 40-bit RC2 and the private key with
+//     3DES, both with SHA-1 based key derivation, and a SHA-1 MAC. This is what
+//     OpenSSL 1.x creates by default and what older Windows and Java versions
+//     expect.
+//   - modern: certificates and private key encrypted with AES-256-CBC and
+//     PBKDF2 (HMAC-SHA-256) key derivation, and a SHA-256 MAC. This is what
+//     OpenSSL 3 creates by default.
+const (
+	Pkcs12ProfileLegacy = "legacy"
+	Pkcs12ProfileModern = "modern"
+)
+
+// PKCS#12 MAC algorithms.
+const (
+	Pkcs12MacSHA1   = "SHA1"
+	Pkcs12MacSHA256 = "SHA256"
+	Pkcs12MacSHA512 = "SHA512"
+)
+
+const (
+	pkcs12Iterations = 2048
+	pkcs12SaltLength = 8
+)
+
+// saltReader is the source of salts and IVs for PKCS#12 and zip encryption.
+// Tests replace it to produce fixed vectors.
+var saltReader io.Reader = rand.Reader
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidCertBag                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidPKCS8ShroudedKeyBag      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertTypeX509Certificate  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBEWithSHAAnd3KeyTDES    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHAAnd40BitRC2    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBES2                    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHmacWithSHA256           = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC                = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA1                     = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256                   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA512                   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidJavaTrustedKeyUsage      = asn1.ObjectIdentifier{2, 16, 840, 1, 113894, 746875, 1, 1}
+	oidAnyExtendedKeyUsage      = asn1.ObjectIdentifier{2, 5, 29, 37, 0}
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	AlgorithmIdentifier pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	Kdf              pkix.AlgorithmIdentifier
+	EncryptionScheme pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	Prf        pkix.AlgorithmIdentifier
+}
+
+// Pkcs12Options configures how a PKCS#12 file is encoded.
+type Pkcs12Options struct {
+	// Password protecting the file. May be empty.
+	Password string
+
+	// Profile is either Pkcs12ProfileLegacy (default) or Pkcs12ProfileModern.
+	Profile string
+
+	// FriendlyName is set on the private key and the leaf certificate. Most
+	// tools show it as the alias of the entry.
+	FriendlyName string
+
+	// MacAlgorithm overrides the MAC algorithm of the profile: SHA1, SHA256 or
+	// SHA512.
+	MacAlgorithm string
+}
+
+// EncodePkcs12 creates a PKCS#12 file with the private key and certificates.
+// The first certificate must be the one matching the private key. If the
+// private key is nil, only the certificates are included.
+func EncodePkcs12(key crypto.Signer, certs []*x509.Certificate, options Pkcs12Options) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates to include in PKCS#12 file")
+	}
+
+	var attributes []pkcs12Attribute
+	if options.FriendlyName != "" {
+		attribute, err := pkcs12BMPStringAttribute(oidFriendlyName, options.FriendlyName)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, attribute)
+	}
+	if key != nil {
+		localKeyID := sha1.Sum(certs[0].Raw)
+		attribute, err := pkcs12OctetStringAttribute(oidLocalKeyID, localKeyID[:])
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, attribute)
+	}
+
+	var certBags []safeBag
+	for i, cert := range certs {
+		var certAttributes []pkcs12Attribute
+		if i == 0 {
+			certAttributes = attributes
+		}
+		bag, err := pkcs12CertBag(cert, certAttributes)
+		if err != nil {
+			return nil, err
+		}
+		certBags = append(certBags, bag)
+	}
+
+	return encodePkcs12(certBags, key, attributes, options)
+}
+
+// EncodePkcs12Truststore creates a PKCS#12 file with the certificates as
+// trusted certificate entries, named `<alias>-ca-<n>`. Entries are marked as
+// trusted for any purpose, which Java requires to use the file as a
+// truststore. options.FriendlyName is not used.
+func EncodePkcs12Truststore(certs []*x509.Certificate, alias string, options Pkcs12Options) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates to include in PKCS#12 file")
+	}
+	trusted, err := pkcs12AttributeValue(oidJavaTrustedKeyUsage, oidAnyExtendedKeyUsage)
+	if err != nil {
+		return nil, err
+	}
+	var certBags []safeBag
+	for i, cert := range certs {
+		friendlyName, err := pkcs12BMPStringAttribute(oidFriendlyName, fmt.Sprintf("%s-ca-%d", alias, i+1))
+		if err != nil {
+			return nil, err
+		}
+		bag, err := pkcs12CertBag(cert, []pkcs12Attribute{friendlyName, trusted})
+		if err != nil {
+			return nil, err
+		}
+		certBags = append(certBags, bag)
+	}
+	return encodePkcs12(certBags, nil, nil, options)
+}
+
+// encodePkcs12 encrypts the certificate bags and the private key, if any,
+// according to the profile and computes the MAC of the PKCS#12 file.
+func encodePkcs12(certBags []safeBag, key crypto.Signer, keyAttributes []pkcs12Attribute, options Pkcs12Options) ([]byte, error) {
+	var err error
+	profile := options.Profile
+	if profile == "" {
+		profile = Pkcs12ProfileLegacy
+	}
+	macAlgorithm := options.MacAlgorithm
+	switch profile {
+	case Pkcs12ProfileLegacy:
+		if macAlgorithm == "" {
+			macAlgorithm = Pkcs12MacSHA1
+		}
+	case Pkcs12ProfileModern:
+		if macAlgorithm == "" {
+			macAlgorithm = Pkcs12MacSHA256
+		}
+	default:
+		return nil, fmt.Errorf("invalid PKCS#12 profile %s", profile)
+	}
+	macHash, macOid, err := pkcs12MacHash(macAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var authenticatedSafe []contentInfo
+	ci, err := pkcs12EncryptedContent(certBags, profile, options.Password)
+	if err != nil {
+		return nil, err
+	}
+	authenticatedSafe = append(authenticatedSafe, ci)
+
+	if key != nil {
+		bag, err := pkcs12KeyBag(key, keyAttributes, profile, options.Password)
+		if err != nil {
+			return nil, err
+		}
+		ci, err = pkcs12DataContent([]safeBag{bag})
+		if err != nil {
+			return nil, err
+		}
+		authenticatedSafe = append(authenticatedSafe, ci)
+	}
+
+	authenticatedSafeBytes, err := asn1.Marshal(authenticatedSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	var pfx pfxPdu
+	pfx.Version = 3
+	pfx.AuthSafe, err = pkcs12RawContent(oidDataContentType, authenticatedSafeBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	pfx.MacData.Iterations = pkcs12Iterations
+	pfx.MacData.MacSalt = make([]byte, pkcs12SaltLength)
+	if _, err = io.ReadFull(saltReader, pfx.MacData.MacSalt); err != nil {
+		return nil, err
+	}
+	macKey := pkcs12KDF(macHash, pkcs12BMPPassword(options.Password), pfx.MacData.MacSalt, 3, pkcs12Iterations, macHash().Size())
+	mac := hmac.New(macHash, macKey)
+	mac.Write(authenticatedSafeBytes)
+	pfx.MacData.Mac.Algorithm = pkix.AlgorithmIdentifier{Algorithm: macOid, Parameters: asn1.NullRawValue}
+	pfx.MacData.Mac.Digest = mac.Sum(nil)
+
+	return asn1.Marshal(pfx)
+}
+
+func pkcs12MacHash(macAlgorithm string) (func() hash.Hash, asn1.ObjectIdentifier, error) {
+	switch macAlgorithm {
+	case Pkcs12MacSHA1:
+		return sha1.New, oidSHA1, nil
+	case Pkcs12MacSHA256:
+		return sha256.New, oidSHA256, nil
+	case Pkcs12MacSHA512:
+		return sha512.New, oidSHA512, nil
+	}
+	return nil, nil, fmt.Errorf("invalid PKCS#12 MAC algorithm %s", macAlgorithm)
+}
+
+func pkcs12CertBag(cert *x509.Certificate, attributes []pkcs12Attribute) (safeBag, error) {
+	var err error
+	bag := safeBag{ID: oidCertBag, Attributes: attributes}
+	bag.Value.Class = asn1.ClassContextSpecific
+	bag.Value.Tag = 0
+	bag.Value.IsCompound = true
+	bag.Value.Bytes, err = asn1.Marshal(certBag{ID: oidCertTypeX509Certificate, Data: cert.Raw})
+	return bag, err
+}
+
+func pkcs12KeyBag(key crypto.Signer, attributes []pkcs12Attribute, profile string, password string) (safeBag, error) {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return safeBag{}, err
+	}
+	var info encryptedPrivateKeyInfo
+	if profile == Pkcs12ProfileModern {
+		info.AlgorithmIdentifier, info.EncryptedData, err = pbes2Encrypt(pkcs8, password)
+	} else {
+		info.AlgorithmIdentifier, info.EncryptedData, err = pbes1Encrypt(oidPBEWithSHAAnd3KeyTDES, pkcs8, password)
+	}
+	if err != nil {
+		return safeBag{}, err
+	}
+
+	bag := safeBag{ID: oidPKCS8ShroudedKeyBag, Attributes: attributes}
+	bag.Value.Class = asn1.ClassContextSpecific
+	bag.Value.Tag = 0
+	bag.Value.IsCompound = true
+	bag.Value.Bytes, err = asn1.Marshal(info)
+	return bag, err
+}
+
+func pkcs12RawContent(contentType asn1.ObjectIdentifier, content interface{}) (contentInfo, error) {
+	var err error
+	ci := contentInfo{ContentType: contentType}
+	ci.Content.Class = asn1.ClassContextSpecific
+	ci.Content.Tag = 0
+	ci.Content.IsCompound = true
+	ci.Content.Bytes, err = asn1.Marshal(content)
+	return ci, err
+}
+
+func pkcs12DataContent(bags []safeBag) (contentInfo, error) {
+	data, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return pkcs12RawContent(oidDataContentType, data)
+}
+
+func pkcs12EncryptedContent(bags []safeBag, profile string, password string) (contentInfo, error) {
+	data, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	var ed encryptedData
+	ed.EncryptedContentInfo.ContentType = oidDataContentType
+	if profile == Pkcs12ProfileModern {
+		ed.EncryptedContentInfo.ContentEncryptionAlgorithm, ed.EncryptedContentInfo.EncryptedContent, err = pbes2Encrypt(data, password)
+	} else {
+		ed.EncryptedContentInfo.ContentEncryptionAlgorithm, ed.EncryptedContentInfo.EncryptedContent, err = pbes1Encrypt(oidPBEWithSHAAnd40BitRC2, data, password)
+	}
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return pkcs12RawContent(oidEncryptedDataContentType, ed)
+}
+
+// pbes1Encrypt encrypts with one of the PKCS#12 password based encryption
+// schemes (RFC 7292, appendix C), using the PKCS#12 key derivation function.
+func pbes1Encrypt(algorithm asn1.ObjectIdentifier, data []byte, password string) (pkix.AlgorithmIdentifier, []byte, error) {
+	var algorithmIdentifier pkix.AlgorithmIdentifier
+	salt := make([]byte, pkcs12SaltLength)
+	if _, err := io.ReadFull(saltReader, salt); err != nil {
+		return algorithmIdentifier, nil, err
+	}
+	params, err := asn1.Marshal(pbeParams{Salt: salt, Iterations: pkcs12Iterations})
+	if err != nil {
+		return algorithmIdentifier, nil, err
+	}
+	algorithmIdentifier = pkix.AlgorithmIdentifier{Algorithm: algorithm, Parameters: asn1.RawValue{FullBytes: params}}
+
+	bmpPassword := pkcs12BMPPassword(password)
+	iv := pkcs12KDF(sha1.New, bmpPassword, salt, 2, pkcs12Iterations, 8)
+	var block cipher.Block
+	if algorithm.Equal(oidPBEWithSHAAnd3KeyTDES) {
+		block, err = des.NewTripleDESCipher(pkcs12KDF(sha1.New, bmpPassword, salt, 1, pkcs12Iterations, 24))
+		if err != nil {
+			return algorithmIdentifier, nil, err
+		}
+	} else {
+		block = newRC2Cipher(pkcs12KDF(sha1.New, bmpPassword, salt, 1, pkcs12Iterations, 5), 40)
+	}
+	return algorithmIdentifier, cbcEncrypt(block, iv, data), nil
+}
+
+// pbes2Encrypt encrypts with PBES2 (RFC 8018), using PBKDF2 with
+// HMAC-SHA-256 and AES-256-CBC.
+func pbes2Encrypt(data []byte, password string) (pkix.AlgorithmIdentifier, []byte, error) {
+	var algorithmIdentifier pkix.AlgorithmIdentifier
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(saltReader, salt); err != nil {
+		return algorithmIdentifier, nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(saltReader, iv); err != nil {
+		return algorithmIdentifier, nil, err
+	}
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: pkcs12Iterations,
+		Prf:        pkix.AlgorithmIdentifier{Algorithm: oidHmacWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return algorithmIdentifier, nil, err
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return algorithmIdentifier, nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		Kdf:              pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		return algorithmIdentifier, nil, err
+	}
+	algorithmIdentifier = pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}}
+
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(password), salt, pkcs12Iterations, 32, sha256.New))
+	if err != nil {
+		return algorithmIdentifier, nil, err
+	}
+	return algorithmIdentifier, cbcEncrypt(block, iv, data), nil
+}
+
+// cbcEncrypt encrypts data in CBC mode, with PKCS#7 padding.
+func cbcEncrypt(block cipher.Block, iv []byte, data []byte) []byte {
+	blockSize := block.BlockSize()
+	padding := blockSize - len(data)%blockSize
+	encrypted := make([]byte, len(data)+padding)
+	copy(encrypted, data)
+	for i := len(data); i < len(encrypted); i++ {
+		encrypted[i] = byte(padding)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+	return encrypted
+}
+
+// pkcs12BMPPassword encodes a password as a null terminated BMPString, as
+// expected by the PKCS#12 key derivation function.
+func pkcs12BMPPassword(password string) []byte {
+	return append(bmpString(password), 0, 0)
+}
+
+func bmpString(s string) []byte {
+	var encoded []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		encoded = append(encoded, byte(c>>8), byte(c))
+	}
+	return encoded
+}
+
+func pkcs12BMPStringAttribute(id asn1.ObjectIdentifier, value string) (pkcs12Attribute, error) {
+	return pkcs12AttributeValue(id, asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagBMPString, Bytes: bmpString(value)})
+}
+
+func pkcs12OctetStringAttribute(id asn1.ObjectIdentifier, value []byte) (pkcs12Attribute, error) {
+	return pkcs12AttributeValue(id, value)
+}
+
+func pkcs12AttributeValue(id asn1.ObjectIdentifier, value interface{}) (pkcs12Attribute, error) {
+	var err error
+	attribute := pkcs12Attribute{ID: id}
+	attribute.Value.Class = asn1.ClassUniversal
+	attribute.Value.Tag = asn1.TagSet
+	attribute.Value.IsCompound = true
+	attribute.Value.Bytes, err = asn1.Marshal(value)
+	return attribute, err
+}
+
+// pkcs12KDF is the PKCS#12 key derivation function (RFC 7292, appendix B.2).
+// The id selects the purpose of the derived material: 1 for encryption keys,
+// 2 for IVs and 3 for MAC keys.
+func pkcs12KDF(h func() hash.Hash, password, salt []byte, id byte, iterations int, size int) []byte {
+	v := h().BlockSize()
+
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+
+	fill := func(in []byte) []byte {
+		if len(in) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(in)+v-1)/v))
+		for i := range out {
+			out[i] = in[i%len(in)]
+		}
+		return out
+	}
+	i := append(fill(salt), fill(password)...)
+
+	one := big.NewInt(1)
+	modulus := new(big.Int).Lsh(one, uint(v*8))
+	var derived []byte
+	for len(derived) < size {
+		hasher := h()
+		hasher.Write(d)
+		hasher.Write(i)
+		a := hasher.Sum(nil)
+		for r := 1; r < iterations; r++ {
+			hasher = h()
+			hasher.Write(a)
+			a = hasher.Sum(nil)
+		}
+		derived = append(derived, a...)
+		if len(derived) >= size {
+			break
+		}
+
+		b := new(big.Int).SetBytes(fill(a)[:v])
+		b.Add(b, one)
+		for j := 0; j < len(i); j += v {
+			ij := new(big.Int).SetBytes(i[j : j+v])
+			ij.Add(ij, b)
+			ij.Mod(ij, modulus)
+			block := ij.Bytes()
+			for k := j; k < j+v; k++ {
+				i[k] = 0
+			}
+			copy(i[j+v-len(block):j+v], block)
+		}
+	}
+	return derived[:size]
+}
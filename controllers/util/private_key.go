@@ -0,0 +1,62 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// EncodePKCS1PrivateKey encodes the private key in its traditional PEM
+// format: PKCS#1 for RSA keys ("RSA PRIVATE KEY") and SEC 1 for EC keys
+// ("EC PRIVATE KEY"). Other key types have no such format.
+func EncodePKCS1PrivateKey(key crypto.Signer) ([]byte, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}), nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T, only RSA and EC keys have a PKCS#1 format", key)
+}
+
+// EncodePKCS8PrivateKey encodes the private key in unencrypted PKCS#8 PEM
+// format ("PRIVATE KEY").
+func EncodePKCS8PrivateKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// EncodeEncryptedPKCS8PrivateKey encodes the private key in encrypted PKCS#8
+// PEM format ("ENCRYPTED PRIVATE KEY"), using PBES2 with PBKDF2-HMAC-SHA256
+// and AES-256-CBC, the same OpenSSL uses by default.
+func EncodeEncryptedPKCS8PrivateKey(key crypto.Signer, password string) ([]byte, error) {
+	if password == "" {
+		return nil, errors.New("password is required to encrypt the private key")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	var info encryptedPrivateKeyInfo
+	info.AlgorithmIdentifier, info.EncryptedData, err = pbes2Encrypt(der, password)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encrypted}), nil
+}
@@ -1,91 +1,206 @@
 package util
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/textproto"
+	"sort"
 	"strings"
 
 	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
 	"github.com/magiconair/properties"
 	mail "github.com/xhit/go-simple-mail/v2"
+	"golang.org/x/crypto/openpgp"
 )
 
-func ProcessEmail(cw *certwatchv1.CertWatcher, certFilesDir string, emailConfiguration *properties.Properties) error {
+// Headers that are managed by ProcessEmail itself and cannot be overridden by
+// custom headers.
+var reservedEmailHeaders = map[string]struct{}{
+	"From":                      {},
+	"To":                        {},
+	"Cc":                        {},
+	"Bcc":                       {},
+	"Reply-To":                  {},
+	"Subject":                   {},
+	"Date":                      {},
+	"Mime-Version":              {},
+	"Content-Type":              {},
+	"Content-Transfer-Encoding": {},
+}
+
+// EmailResources holds values ProcessEmail needs which are read from other
+// Kubernetes resources while reconciling.
+type EmailResources struct {
+	// PgpRecipients are the public keys messages are encrypted to, when the
+	// e-mail action has PGP enabled.
+	PgpRecipients openpgp.EntityList
+
+	// OAuth2Credentials are the values of the Secret referenced by
+	// `oauth2.credentialSecret`, when the email configuration uses XOAUTH2
+	// authentication.
+	OAuth2Credentials map[string][]byte
+
+	// InlineImages are the images, by name, from the ConfigMap referenced by
+	// inlineImagesConfigMap.
+	InlineImages map[string][]byte
+}
+
+// ProcessEmail sends the e-mail configured in the CertWatcher, with
+// attachments taken from the temporary workspace directory. Messages are
+// delivered through the shared MailSender, which pools connections and
+// retries transient failures.
+//
+// Authentication is determined by the `authentication` value in the email
+// configuration. It defaults to plain username/password authentication, or
+// `xoauth2` to authenticate with an OAuth2 access token (see OAuth2Token).
+func ProcessEmail(ctx context.Context, sender *MailSender, cw *certwatchv1.CertWatcher, certFilesDir string, emailConfiguration *properties.Properties, resources EmailResources) error {
 	var err error
+	var spec = cw.Spec.Actions.Email
 
 	if emailConfiguration == nil {
 		return errors.New("email not configured")
 	}
 
-	server := mail.NewSMTPClient()
-	server.Host = emailConfiguration.MustGetString("host")
-	server.Port = emailConfiguration.MustGetInt("port")
-	server.Username = emailConfiguration.GetString("username", "")
-	server.Password = emailConfiguration.GetString("password", "")
+	pgpMode, err := emailPgpMode(spec, resources)
+	if err != nil {
+		return err
+	}
 
-	sEncryptionType := emailConfiguration.GetString("encryption", "")
-	switch sEncryptionType {
-	case "SSL":
-		server.Encryption = mail.EncryptionSSL
-	case "TLS":
-		server.Encryption = mail.EncryptionTLS
-	case "SSLTLS":
-		server.Encryption = mail.EncryptionSSLTLS
-	case "STARTTLS":
-		server.Encryption = mail.EncryptionSTARTTLS
-	default:
-		server.Encryption = mail.EncryptionNone
+	email, err := newEmail(spec, emailConfiguration)
+	if err != nil {
+		return err
 	}
+	email.SetSubject(spec.Subject)
 
-	var from string
-	if cw.Spec.Actions.Email.From != "" {
-		from = cw.Spec.Actions.Email.From
+	var emailContentType = mail.TextPlain
+	if spec.BodyContentType == "text/html" {
+		emailContentType = mail.TextHTML
+	}
+
+	// Clients prefer the last part of a multipart/alternative body, so the
+	// plain text version always goes first and the HTML version last.
+	if spec.AlternativeBodyTemplate == "" {
+		email.SetBody(emailContentType, spec.BodyTemplate)
+	} else if emailContentType == mail.TextHTML {
+		email.SetBody(mail.TextPlain, spec.AlternativeBodyTemplate)
+		email.AddAlternative(mail.TextHTML, spec.BodyTemplate)
 	} else {
-		from = emailConfiguration.MustGetString("from")
+		email.SetBody(mail.TextPlain, spec.BodyTemplate)
+		email.AddAlternative(mail.TextHTML, spec.AlternativeBodyTemplate)
 	}
 
-	smtpClient, err := server.Connect()
-	if err != nil {
-		return err
+	var imageNames []string
+	for name := range resources.InlineImages {
+		imageNames = append(imageNames, name)
+	}
+	sort.Strings(imageNames)
+	for _, name := range imageNames {
+		email.Attach(&mail.File{Data: resources.InlineImages[name], Name: name, Inline: true})
+		if email.Error != nil {
+			return email.Error
+		}
+	}
+
+	for _, f := range spec.Attachments {
+		if pgpMode == PgpModeAttachments {
+			f, err = PgpEncryptFile(certFilesDir, f, resources.PgpRecipients, spec.Pgp.Armor)
+			if err != nil {
+				return err
+			}
+		}
+		email.Attach(&mail.File{FilePath: certFilesDir + "/" + f, Name: f})
+		if email.Error != nil {
+			return email.Error
+		}
+	}
+
+	return sendEmail(ctx, sender, email, pgpMode, emailConfiguration, resources)
+}
+
+// emailPgpMode validates the PGP configuration of the e-mail action and
+// returns the PGP mode in use, or an empty string if PGP is not enabled.
+func emailPgpMode(spec *certwatchv1.CertWatchActionEmail, resources EmailResources) (string, error) {
+	if spec.Pgp == nil {
+		return "", nil
+	}
+	pgpMode := spec.Pgp.Mode
+	if pgpMode == "" {
+		pgpMode = PgpModeAttachments
+	}
+	if pgpMode != PgpModeAttachments && pgpMode != PgpModeBody {
+		return "", fmt.Errorf("invalid PGP mode %s", pgpMode)
+	}
+	if len(resources.PgpRecipients) == 0 {
+		return "", errors.New("PGP enabled, but no recipient public keys available")
+	}
+	return pgpMode, nil
+}
+
+// newEmail creates a new message with all addressing and custom headers from
+// the e-mail action. Subject, body and attachments are left to the caller.
+func newEmail(spec *certwatchv1.CertWatchActionEmail, emailConfiguration *properties.Properties) (*mail.Email, error) {
+	var from string
+	if spec.From != "" {
+		from = spec.From
+	} else {
+		from = emailConfiguration.MustGetString("from")
 	}
 
 	email := mail.NewMSG()
 	email.SetFrom(from)
 	// var emailString string
-	for _, emailString := range strings.Split(cw.Spec.Actions.Email.To, ",") {
+	for _, emailString := range strings.Split(spec.To, ",") {
 		email.AddTo(emailString)
 	}
-	if cw.Spec.Actions.Email.Cc != "" {
+	if spec.Cc != "" {
 		// email.AddCc(cw.Spec.Actions.Email.Cc)
-		for _, emailString := range strings.Split(cw.Spec.Actions.Email.Cc, ",") {
+		for _, emailString := range strings.Split(spec.Cc, ",") {
 			email.AddCc(emailString)
 		}
 	}
-	if cw.Spec.Actions.Email.Bcc != "" {
+	if spec.Bcc != "" {
 		// email.AddBcc(cw.Spec.Actions.Email.Bcc)
-		for _, emailString := range strings.Split(cw.Spec.Actions.Email.Bcc, ",") {
+		for _, emailString := range strings.Split(spec.Bcc, ",") {
 			email.AddBcc(emailString)
 		}
 	}
-	email.SetSubject(cw.Spec.Actions.Email.Subject)
+	if spec.ReplyTo != "" {
+		email.SetReplyTo(spec.ReplyTo)
+	}
 
-	var emailContentType = mail.TextPlain
-	if cw.Spec.Actions.Email.BodyContentType == "text/html" {
-		emailContentType = mail.TextHTML
+	var headerNames []string
+	for header := range spec.Headers {
+		headerNames = append(headerNames, header)
 	}
+	sort.Strings(headerNames)
+	for _, header := range headerNames {
+		if _, ok := reservedEmailHeaders[textproto.CanonicalMIMEHeaderKey(header)]; ok {
+			return nil, fmt.Errorf("header %s cannot be set as a custom header", header)
+		}
+		email.AddHeader(header, spec.Headers[header])
+	}
+	return email, email.Error
+}
 
-	email.SetBody(emailContentType, cw.Spec.Actions.Email.BodyTemplate)
-	for _, f := range cw.Spec.Actions.Email.Attachments {
-		email.Attach(&mail.File{FilePath: certFilesDir + "/" + f, Name: f})
-		if email.Error != nil {
-			return email.Error
+// sendEmail finishes the message, encrypting the whole body when PGP is
+// enabled in body mode, and hands it over to the MailSender.
+func sendEmail(ctx context.Context, sender *MailSender, email *mail.Email, pgpMode string, emailConfiguration *properties.Properties, resources EmailResources) error {
+	var err error
+	if email.Error != nil {
+		return email.Error
+	}
+	var msg = email.GetMessage()
+	if pgpMode == PgpModeBody {
+		msg, err = PgpMimeMessage(msg, resources.PgpRecipients)
+		if err != nil {
+			return err
 		}
 	}
 
 	// Send email
-	err = email.Send(smtpClient)
-	if err != nil {
-		return err
+	if sender == nil {
+		return errors.New("mail sender not available")
 	}
-
-	return nil
+	return sender.Send(ctx, emailConfiguration, resources.OAuth2Credentials, email.GetFrom(), email.GetRecipients(), msg)
 }
@@ -2,6 +2,9 @@ package util
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+
 	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
 	v1 "k8s.io/api/batch/v1"
 	apicorev1 "k8s.io/api/core/v1"
@@ -34,15 +37,59 @@ func ProcessJob(certwatcher *certwatchv1.CertWatcher) (*v1.Job, error) {
 		},
 		Spec: certwatcher.Spec.Actions.Job.Spec,
 	}
+	if certwatcher.Spec.ConfigMap != nil {
+		job.Namespace = certwatcher.Spec.ConfigMap.Namespace
+	}
+	if certwatcher.Spec.Endpoint != nil {
+		job.Namespace = certwatcher.Namespace
+	}
 
-	// Create an additional volume in the pod spec
-	job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, apicorev1.Volume{
-		Name: certwatcher.Spec.Actions.Job.VolumeName,
-		VolumeSource: apicorev1.VolumeSource{
-			Secret: &apicorev1.SecretVolumeSource{
-				SecretName: certwatcher.Spec.Secret.Name,
-			},
+	// Create an additional volume in the pod spec. When certificate files are
+	// requested, the volume projects both the original Secret, or ConfigMap,
+	// and the Secret holding the files (see JobFilesSecret).
+	var volumeSource = apicorev1.VolumeSource{
+		Secret: &apicorev1.SecretVolumeSource{
+			SecretName: certwatcher.Spec.Secret.Name,
 		},
+	}
+	var sourceProjection = apicorev1.VolumeProjection{
+		Secret: &apicorev1.SecretProjection{LocalObjectReference: apicorev1.LocalObjectReference{Name: certwatcher.Spec.Secret.Name}},
+	}
+	if certwatcher.Spec.ConfigMap != nil {
+		volumeSource = apicorev1.VolumeSource{
+			ConfigMap: &apicorev1.ConfigMapVolumeSource{
+				LocalObjectReference: apicorev1.LocalObjectReference{Name: certwatcher.Spec.ConfigMap.Name},
+			},
+		}
+		sourceProjection = apicorev1.VolumeProjection{
+			ConfigMap: &apicorev1.ConfigMapProjection{LocalObjectReference: apicorev1.LocalObjectReference{Name: certwatcher.Spec.ConfigMap.Name}},
+		}
+	}
+	var filesProjection = apicorev1.VolumeProjection{
+		Secret: &apicorev1.SecretProjection{LocalObjectReference: apicorev1.LocalObjectReference{Name: jobname}},
+	}
+	if len(certwatcher.Spec.Actions.Job.Files) > 0 {
+		volumeSource = apicorev1.VolumeSource{
+			Projected: &apicorev1.ProjectedVolumeSource{
+				Sources: []apicorev1.VolumeProjection{sourceProjection, filesProjection},
+			},
+		}
+	}
+	// Certificates presented by an endpoint are not stored anywhere, so only
+	// the files requested are available.
+	if certwatcher.Spec.Endpoint != nil {
+		if len(certwatcher.Spec.Actions.Job.Files) == 0 {
+			return nil, fmt.Errorf("files are required for Jobs of CertWatchers watching endpoints")
+		}
+		volumeSource = apicorev1.VolumeSource{
+			Projected: &apicorev1.ProjectedVolumeSource{
+				Sources: []apicorev1.VolumeProjection{filesProjection},
+			},
+		}
+	}
+	job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, apicorev1.Volume{
+		Name:         certwatcher.Spec.Actions.Job.VolumeName,
+		VolumeSource: volumeSource,
 	})
 
 	// Create a volumeMount in each container in the pod spec
@@ -57,3 +104,35 @@ func ProcessJob(certwatcher *certwatchv1.CertWatcher) (*v1.Job, error) {
 
 	return &job, nil
 }
+
+// JobFilesSecret creates the Secret holding the certificate files requested
+// by the Job action, read from the temporary workspace directory. The Secret
+// has the same name as the Job, which owns it, so it is removed along with the
+// Job. The owner reference has no UID until the Job is created, so it must be
+// set before the Secret is created.
+func JobFilesSecret(certwatcher *certwatchv1.CertWatcher, job *v1.Job, certFilesDir string) (*apicorev1.Secret, error) {
+	var secret = apicorev1.Secret{
+		ObjectMeta: apimachineryv1.ObjectMeta{
+			Namespace: job.Namespace,
+			Name:      job.Name,
+			OwnerReferences: []apimachineryv1.OwnerReference{
+				{
+					APIVersion: "batch/v1",
+					Kind:       "Job",
+					Name:       job.Name,
+					UID:        job.UID,
+				},
+			},
+		},
+		Type: apicorev1.SecretTypeOpaque,
+		Data: map[string][]byte{},
+	}
+	for _, f := range certwatcher.Spec.Actions.Job.Files {
+		data, err := os.ReadFile(filepath.Join(certFilesDir, f))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %s", f, err.Error())
+		}
+		secret.Data[f] = data
+	}
+	return &secret, nil
+}
@@ -0,0 +1,104 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	"github.com/magiconair/properties"
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+const (
+	defaultRandomPasswordLength = 24
+	minRandomPasswordLength     = 12
+	randomPasswordAlphabet      = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+	passwordWebhookTimeout      = 30 * time.Second
+)
+
+// GeneratePassword generates a random password from letters and digits,
+// leaving out look-alike characters such as 0, O, 1, l and I, so it can be
+// typed from an e-mail. A zero length means the default of 24 characters.
+func GeneratePassword(length int) (string, error) {
+	if length == 0 {
+		length = defaultRandomPasswordLength
+	}
+	if length < minRandomPasswordLength {
+		return "", fmt.Errorf("random password length must be at least %d", minRandomPasswordLength)
+	}
+	var password = make([]byte, length)
+	var max = big.NewInt(int64(len(randomPasswordAlphabet)))
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = randomPasswordAlphabet[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// PasswordNotification is what random password deliveries tell about the
+// change the password belongs to.
+type PasswordNotification struct {
+	CertWatcher string `json:"certWatcher"`
+	Secret      string `json:"secret"`
+	Checksum    string `json:"checksum"`
+	Password    string `json:"password"`
+}
+
+// ProcessPasswordEmail sends the random password in an e-mail of its own,
+// without any attachments.
+func ProcessPasswordEmail(ctx context.Context, sender *MailSender, spec *certwatchv1.CertWatcherPasswordEmail, emailConfiguration *properties.Properties, resources EmailResources, notification PasswordNotification) error {
+	if emailConfiguration == nil {
+		return errors.New("email not configured")
+	}
+	email, err := newEmail(&certwatchv1.CertWatchActionEmail{From: spec.From, To: spec.To}, emailConfiguration)
+	if err != nil {
+		return err
+	}
+	subject := "Certificate files password"
+	if spec.Subject != "" {
+		subject = spec.Subject
+	}
+	email.SetSubject(subject)
+	email.SetBody(mail.TextPlain, fmt.Sprintf(
+		"Certificate files sent by CertWatcher %s for Secret %s are protected with the password below. It is only valid for this change of the Secret.\n\n%s\n",
+		notification.CertWatcher, notification.Secret, notification.Password))
+	return sendEmail(ctx, sender, email, "", emailConfiguration, resources)
+}
+
+// ProcessPasswordWebhook posts the random password to the webhook as JSON,
+// along with the headers given. Any status other than 2xx is an error.
+func ProcessPasswordWebhook(ctx context.Context, spec *certwatchv1.CertWatcherPasswordWebhook, headers map[string][]byte, notification PasswordNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, passwordWebhookTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, spec.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		request.Header.Set(name, string(value))
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned %s", spec.URL, response.Status)
+	}
+	return nil
+}
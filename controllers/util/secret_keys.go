@@ -0,0 +1,98 @@
+package util
+
+import (
+	"fmt"
+
+	apicorev1 "k8s.io/api/core/v1"
+
+	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+)
+
+// Keys of the certificate data in kubernetes.io/tls Secrets, which is where
+// CreateCertificateFiles and ValidateCertificate look for it.
+const (
+	SecretCertificateKey = "tls.crt"
+	SecretPrivateKeyKey  = "tls.key"
+	SecretCAKey          = "ca.crt"
+)
+
+// SecretTypeSupported reports whether a Secret can be watched with the given
+// keys. kubernetes.io/tls Secrets always are, while Opaque Secrets need their
+// keys to be declared.
+func SecretTypeSupported(secret *apicorev1.Secret, keys *certwatchv1.CertWatcherSecretKeys) bool {
+	switch secret.Type {
+	case apicorev1.SecretTypeTLS:
+		return true
+	case apicorev1.SecretTypeOpaque, "":
+		return keys != nil
+	}
+	return false
+}
+
+// NormalizeSecretKeys returns a copy of the Secret with its certificate data
+// moved from the configured keys to the ones of kubernetes.io/tls Secrets.
+// The Secret is returned unchanged when keys is nil.
+func NormalizeSecretKeys(secret *apicorev1.Secret, keys *certwatchv1.CertWatcherSecretKeys) (*apicorev1.Secret, error) {
+	if keys == nil {
+		return secret, nil
+	}
+	var secretname = fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)
+	var certificateKey = defaultString(keys.Certificate, SecretCertificateKey)
+	var privateKeyKey = defaultString(keys.PrivateKey, SecretPrivateKeyKey)
+	var caKey = defaultString(keys.CA, SecretCAKey)
+
+	if !SecretTypeSupported(secret, keys) {
+		return nil, fmt.Errorf("secret %s has unsupported type %s", secretname, secret.Type)
+	}
+	tlsCrt, ok := secret.Data[certificateKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s does not have value for %s", secretname, certificateKey)
+	}
+	tlsKey, ok := secret.Data[privateKeyKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s does not have value for %s", secretname, privateKeyKey)
+	}
+
+	var normalized = secret.DeepCopy()
+	normalized.Type = apicorev1.SecretTypeTLS
+	normalized.Data = map[string][]byte{
+		SecretCertificateKey: tlsCrt,
+		SecretPrivateKeyKey:  tlsKey,
+	}
+	if caCrt, ok := secret.Data[caKey]; ok {
+		normalized.Data[SecretCAKey] = caCrt
+	}
+	return normalized, nil
+}
+
+func defaultString(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// DefaultConfigMapKey is the key of the certificates in a ConfigMap, unless
+// configured otherwise.
+const DefaultConfigMapKey = "ca.crt"
+
+// ConfigMapAsSecret returns a Secret holding the certificates found in a key
+// of the ConfigMap as tls.crt, without private key, so they can be handled
+// like the contents of any Secret in certificate-only mode (see
+// CertificateFilesOptions).
+func ConfigMapAsSecret(cm *apicorev1.ConfigMap, key string) (*apicorev1.Secret, error) {
+	key = defaultString(key, DefaultConfigMapKey)
+	var certificates []byte
+	if value, ok := cm.Data[key]; ok {
+		certificates = []byte(value)
+	} else if value, ok := cm.BinaryData[key]; ok {
+		certificates = value
+	} else {
+		return nil, fmt.Errorf("configmap %s/%s does not have value for %s", cm.Namespace, cm.Name, key)
+	}
+	return &apicorev1.Secret{
+		ObjectMeta: *cm.ObjectMeta.DeepCopy(),
+		Type:       apicorev1.SecretTypeTLS,
+		Data:       map[string][]byte{SecretCertificateKey: certificates},
+	}, nil
+}
@@ -0,0 +1,208 @@
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/magiconair/properties"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	OAuth2GrantClientCredentials = "client_credentials"
+	OAuth2GrantRefreshToken      = "refresh_token"
+)
+
+// Token sources are cached for the lifetime of the controller, so access
+// tokens are reused across reconciles and only refreshed when they expire.
+// There is one token source per token endpoint, grant type, scopes and client
+// ID, replaced when the rest of the credentials change.
+var oauth2TokenSources = map[string]oauth2TokenSource{}
+var oauth2TokenSourcesMutex sync.Mutex
+
+type oauth2TokenSource struct {
+	credentials string
+	oauth2.TokenSource
+}
+
+// OAuth2Token returns a valid access token for the SMTP server described in
+// the email configuration. Tokens are requested from the `oauth2.tokenUrl`
+// endpoint, using the grant type in `oauth2.grantType` (client_credentials or
+// refresh_token, defaults to client_credentials). Optional scopes are taken
+// from `oauth2.scopes` as a comma separated list.
+//
+// Credentials come from the values of a Secret: clientId, clientSecret and,
+// for the refresh_token grant, refreshToken.
+func OAuth2Token(emailConfiguration *properties.Properties, credentials map[string][]byte) (string, error) {
+	tokenURL := emailConfiguration.GetString("oauth2.tokenUrl", "")
+	if tokenURL == "" {
+		return "", errors.New("missing email configuration value: oauth2.tokenUrl")
+	}
+	grantType := emailConfiguration.GetString("oauth2.grantType", OAuth2GrantClientCredentials)
+	var scopes []string
+	if s := emailConfiguration.GetString("oauth2.scopes", ""); s != "" {
+		for _, scope := range strings.Split(s, ",") {
+			scopes = append(scopes, strings.TrimSpace(scope))
+		}
+	}
+
+	clientID, ok := credentials["clientId"]
+	if !ok {
+		return "", errors.New("missing OAuth2 credential value: clientId")
+	}
+	clientSecret := credentials["clientSecret"]
+	refreshToken := credentials["refreshToken"]
+
+	key := strings.Join([]string{tokenURL, grantType, strings.Join(scopes, ","), string(clientID)}, "\x00")
+	hash := sha256.New()
+	for _, v := range [][]byte{clientSecret, refreshToken} {
+		hash.Write(v)
+		hash.Write([]byte{0})
+	}
+	credentialsHash := hex.EncodeToString(hash.Sum(nil))
+
+	oauth2TokenSourcesMutex.Lock()
+	cached, ok := oauth2TokenSources[key]
+	var tokenSource = cached.TokenSource
+	if !ok || cached.credentials != credentialsHash {
+		switch grantType {
+		case OAuth2GrantClientCredentials:
+			config := clientcredentials.Config{
+				ClientID:     string(clientID),
+				ClientSecret: string(clientSecret),
+				TokenURL:     tokenURL,
+				Scopes:       scopes,
+			}
+			tokenSource = config.TokenSource(context.Background())
+		case OAuth2GrantRefreshToken:
+			if len(refreshToken) == 0 {
+				oauth2TokenSourcesMutex.Unlock()
+				return "", errors.New("missing OAuth2 credential value: refreshToken")
+			}
+			config := oauth2.Config{
+				ClientID:     string(clientID),
+				ClientSecret: string(clientSecret),
+				Endpoint:     oauth2.Endpoint{TokenURL: tokenURL},
+				Scopes:       scopes,
+			}
+			tokenSource = config.TokenSource(context.Background(), &oauth2.Token{RefreshToken: string(refreshToken)})
+		default:
+			oauth2TokenSourcesMutex.Unlock()
+			return "", fmt.Errorf("invalid OAuth2 grant type %s", grantType)
+		}
+		oauth2TokenSources[key] = oauth2TokenSource{credentials: credentialsHash, TokenSource: tokenSource}
+	}
+	oauth2TokenSourcesMutex.Unlock()
+
+	token, err := tokenSource.Token()
+	if err != nil {
+		return "", fmt.Errorf("unable to obtain OAuth2 token from %s: %w", tokenURL, err)
+	}
+	return token.AccessToken, nil
+}
+
+// xoauth2Auth implements the SASL XOAUTH2 mechanism used by most mail
+// providers that no longer accept basic authentication.
+type xoauth2Auth struct {
+	username string
+	token    string
+}
+
+// Start refuses to send the token over a connection without TLS, like
+// smtp.PlainAuth does with passwords.
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS {
+		return "", nil, errors.New("unencrypted connection, XOAUTH2 requires encryption SSL, SSLTLS, TLS or STARTTLS")
+	}
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// The server sends a JSON error challenge when authentication fails.
+		// An empty response is expected, after which the server replies with
+		// the final error.
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// dialXOAuth2 connects to the SMTP server described in the email
+// configuration and authenticates with XOAUTH2.
+func dialXOAuth2(emailConfiguration *properties.Properties, token string) (*smtp.Client, error) {
+	host := emailConfiguration.MustGetString("host")
+	addr := net.JoinHostPort(host, strconv.Itoa(emailConfiguration.MustGetInt("port")))
+	tlsConfig := &tls.Config{ServerName: host}
+
+	var conn net.Conn
+	var err error
+	encryption := emailConfiguration.GetString("encryption", "")
+	switch encryption {
+	case "SSL", "SSLTLS":
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, tlsConfig)
+	default:
+		conn, err = net.DialTimeout("tcp", addr, 10*time.Second)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if encryption == "TLS" || encryption == "STARTTLS" {
+		if err = c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if err = c.Auth(&xoauth2Auth{username: emailConfiguration.GetString("username", ""), token: token}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+type xoauth2Connection struct {
+	client *smtp.Client
+}
+
+func (c *xoauth2Connection) send(from string, recipients []string, msg string) error {
+	if err := c.client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if err := c.client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := c.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.client.Reset()
+}
+
+func (c *xoauth2Connection) close() {
+	_ = c.client.Quit()
+	_ = c.client.Close()
+}
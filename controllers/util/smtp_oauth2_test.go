@@ -0,0 +1,167 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/magiconair/properties"
+)
+
+// tokenEndpoint is a local stand-in for an OAuth2 token endpoint, issuing
+// numbered access tokens that expire after expiresIn seconds.
+type tokenEndpoint struct {
+	expiresIn int
+	requests  []map[string]string
+	mutex     sync.Mutex
+}
+
+func (e *tokenEndpoint) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var request = map[string]string{}
+	for k := range req.PostForm {
+		request[k] = req.PostForm.Get(k)
+	}
+	if id, secret, ok := req.BasicAuth(); ok {
+		request["client_id"] = id
+		request["client_secret"] = secret
+	}
+	e.mutex.Lock()
+	e.requests = append(e.requests, request)
+	var n = len(e.requests)
+	e.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": fmt.Sprintf("token-%d", n),
+		"token_type":   "Bearer",
+		"expires_in":   e.expiresIn,
+	})
+}
+
+func oauth2Configuration(tokenURL string, grantType string) *properties.Properties {
+	var p = properties.NewProperties()
+	p.MustSet("oauth2.tokenUrl", tokenURL)
+	p.MustSet("oauth2.grantType", grantType)
+	p.MustSet("oauth2.scopes", "smtp")
+	return p
+}
+
+func TestOAuth2TokenClientCredentials(t *testing.T) {
+	var endpoint = &tokenEndpoint{expiresIn: 3600}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	var config = oauth2Configuration(server.URL, OAuth2GrantClientCredentials)
+	var credentials = map[string][]byte{"clientId": []byte("id"), "clientSecret": []byte("secret")}
+	for i := 0; i < 2; i++ {
+		token, err := OAuth2Token(config, credentials)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "token-1" {
+			t.Errorf("expected cached token-1, got %s", token)
+		}
+	}
+	if len(endpoint.requests) != 1 {
+		t.Fatalf("expected 1 token request, got %d", len(endpoint.requests))
+	}
+	var request = endpoint.requests[0]
+	if request["grant_type"] != "client_credentials" || request["client_id"] != "id" || request["client_secret"] != "secret" || request["scope"] != "smtp" {
+		t.Errorf("unexpected token request %v", request)
+	}
+}
+
+func TestOAuth2TokenRefreshToken(t *testing.T) {
+	// Tokens expiring within a few seconds are refreshed on every call.
+	var endpoint = &tokenEndpoint{expiresIn: 1}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	var config = oauth2Configuration(server.URL, OAuth2GrantRefreshToken)
+	var credentials = map[string][]byte{"clientId": []byte("id"), "clientSecret": []byte("secret"), "refreshToken": []byte("refresh")}
+	for i := 1; i <= 2; i++ {
+		token, err := OAuth2Token(config, credentials)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != fmt.Sprintf("token-%d", i) {
+			t.Errorf("expected refreshed token-%d, got %s", i, token)
+		}
+	}
+	for _, request := range endpoint.requests {
+		if request["grant_type"] != "refresh_token" || request["refresh_token"] != "refresh" || request["client_id"] != "id" {
+			t.Errorf("unexpected token request %v", request)
+		}
+	}
+
+	if _, err := OAuth2Token(config, map[string][]byte{"clientId": []byte("id")}); err == nil {
+		t.Error("expected an error without refreshToken")
+	}
+}
+
+func TestOAuth2TokenCredentialsChanged(t *testing.T) {
+	var endpoint = &tokenEndpoint{expiresIn: 3600}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	var config = oauth2Configuration(server.URL, OAuth2GrantClientCredentials)
+	for _, secret := range []string{"old", "new"} {
+		if _, err := OAuth2Token(config, map[string][]byte{"clientId": []byte("rotated"), "clientSecret": []byte(secret)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(endpoint.requests) != 2 || endpoint.requests[1]["client_secret"] != "new" {
+		t.Errorf("expected a new token request with the new secret, got %v", endpoint.requests)
+	}
+	var cached int
+	oauth2TokenSourcesMutex.Lock()
+	for key := range oauth2TokenSources {
+		if strings.HasPrefix(key, server.URL+"\x00") {
+			cached++
+		}
+	}
+	oauth2TokenSourcesMutex.Unlock()
+	if cached != 1 {
+		t.Errorf("expected the token source to be replaced, got %d cached", cached)
+	}
+}
+
+func TestOAuth2TokenError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+	}))
+	defer server.Close()
+
+	_, err := OAuth2Token(oauth2Configuration(server.URL, OAuth2GrantClientCredentials), map[string][]byte{"clientId": []byte("id")})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if isTransientConnectError(err) {
+		t.Errorf("expected rejected client credentials not to be retried: %s", err.Error())
+	}
+}
+
+func TestXOAuth2AuthRequiresTLS(t *testing.T) {
+	var auth = &xoauth2Auth{username: "user", token: "secret-token"}
+	if _, _, err := auth.Start(&smtp.ServerInfo{Name: "mail.example.com", TLS: false, Auth: []string{"XOAUTH2"}}); err == nil {
+		t.Error("expected the token not to be sent without TLS")
+	}
+	mechanism, response, err := auth.Start(&smtp.ServerInfo{Name: "mail.example.com", TLS: true, Auth: []string{"XOAUTH2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mechanism != "XOAUTH2" || string(response) != "user=user\x01auth=Bearer secret-token\x01\x01" {
+		t.Errorf("unexpected XOAUTH2 response %s %q", mechanism, response)
+	}
+}
@@ -0,0 +1,203 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+
+	apicorev1 "k8s.io/api/core/v1"
+)
+
+// Reasons of certificate validation failures, as used in the CertificateValid
+// condition of CertWatchers.
+const (
+	ValidationReasonValid              = "Valid"
+	ValidationReasonMissingData        = "MissingData"
+	ValidationReasonInvalidPrivateKey  = "InvalidPrivateKey"
+	ValidationReasonInvalidCertificate = "InvalidCertificate"
+	ValidationReasonKeyMismatch        = "KeyMismatch"
+	ValidationReasonNotYetValid        = "NotYetValid"
+	ValidationReasonExpired            = "Expired"
+	ValidationReasonWeakKey            = "WeakKey"
+	ValidationReasonSignatureAlgorithm = "SignatureAlgorithmNotAllowed"
+	ValidationReasonChainInvalid       = "ChainInvalid"
+	ValidationReasonInvalidCABundle    = "InvalidCABundle"
+)
+
+// ValidationError is a certificate validation failure, with the reason it
+// failed.
+type ValidationError struct {
+	Reason  string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func validationErrorf(reason string, format string, a ...interface{}) *ValidationError {
+	return &ValidationError{Reason: reason, Message: fmt.Sprintf(format, a...)}
+}
+
+// ValidationOptions configures the checks ValidateCertificate performs on top
+// of the ones that are always made.
+type ValidationOptions struct {
+	// VerifyChain verifies the chain of the leaf certificate up to a root,
+	// either from CABundle or, without it, from tls.crt and ca.crt.
+	VerifyChain bool
+
+	// CABundle are the trusted roots to verify the chain against.
+	CABundle []*x509.Certificate
+
+	// MinRSAKeySize and MinECKeySize are the minimum sizes, in bits, of the
+	// leaf certificate public key. Zero means any size.
+	MinRSAKeySize int
+	MinECKeySize  int
+
+	// SignatureAlgorithms allowed in the leaf and intermediate certificates,
+	// such as SHA256-RSA or ECDSA-SHA384. Empty means any algorithm.
+	SignatureAlgorithms []string
+
+	// Now is the time validity is checked at. Defaults to the current time.
+	Now time.Time
+}
+
+// ValidateCertificate checks the contents of the Secret before they are
+// handed to any action. Private key and certificates must be valid PEM, the
+// key must match one of the certificates, the leaf, and the leaf must be
+// currently valid. Chain verification and policies are checked as
+// configured in options. Failures are returned as a *ValidationError.
+func ValidateCertificate(secret *apicorev1.Secret, options ValidationOptions) error {
+	var secretname = fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)
+	var now = options.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	tlsKey, ok := secret.Data["tls.key"]
+	if !ok {
+		return validationErrorf(ValidationReasonMissingData, "secret %s does not have value for tls.key", secretname)
+	}
+	tlsCrt, ok := secret.Data["tls.crt"]
+	if !ok {
+		return validationErrorf(ValidationReasonMissingData, "secret %s does not have value for tls.crt", secretname)
+	}
+	key, err := ParsePrivateKey(tlsKey)
+	if err != nil {
+		return validationErrorf(ValidationReasonInvalidPrivateKey, "cannot parse tls.key from secret %s: %s", secretname, err.Error())
+	}
+	certs, err := ParseCertificates(tlsCrt)
+	if err != nil {
+		return validationErrorf(ValidationReasonInvalidCertificate, "cannot parse tls.crt from secret %s: %s", secretname, err.Error())
+	}
+	var cas []*x509.Certificate
+	if caCrt, ok := secret.Data["ca.crt"]; ok && len(caCrt) > 0 {
+		cas, err = ParseCertificates(caCrt)
+		if err != nil {
+			return validationErrorf(ValidationReasonInvalidCertificate, "cannot parse ca.crt from secret %s: %s", secretname, err.Error())
+		}
+	}
+
+	var leaf *x509.Certificate
+	for _, cert := range certs {
+		if KeyMatchesCertificate(key, cert) {
+			leaf = cert
+			break
+		}
+	}
+	if leaf == nil {
+		return validationErrorf(ValidationReasonKeyMismatch, "tls.key from secret %s does not match any tls.crt certificate", secretname)
+	}
+
+	if now.Before(leaf.NotBefore) {
+		return validationErrorf(ValidationReasonNotYetValid, "certificate %s is not valid before %s", certificateName(leaf), leaf.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if now.After(leaf.NotAfter) {
+		return validationErrorf(ValidationReasonExpired, "certificate %s expired at %s", certificateName(leaf), leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	switch pub := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < options.MinRSAKeySize {
+			return validationErrorf(ValidationReasonWeakKey, "certificate %s has a %d bits RSA key, at least %d bits required", certificateName(leaf), pub.N.BitLen(), options.MinRSAKeySize)
+		}
+	case *ecdsa.PublicKey:
+		if size := pub.Curve.Params().BitSize; size < options.MinECKeySize {
+			return validationErrorf(ValidationReasonWeakKey, "certificate %s has a %d bits EC key, at least %d bits required", certificateName(leaf), size, options.MinECKeySize)
+		}
+	}
+
+	chain, unused := BuildCertificateChain(leaf, certs, cas)
+	if len(options.SignatureAlgorithms) > 0 {
+		// The self-signature of the root is not relied upon, so only the leaf
+		// and intermediates are checked.
+		for _, cert := range chain.FullChain() {
+			if !signatureAlgorithmAllowed(cert.SignatureAlgorithm, options.SignatureAlgorithms) {
+				return validationErrorf(ValidationReasonSignatureAlgorithm, "certificate %s is signed with %s, allowed: %s",
+					certificateName(cert), cert.SignatureAlgorithm.String(), strings.Join(options.SignatureAlgorithms, ", "))
+			}
+		}
+	}
+
+	if options.VerifyChain || len(options.CABundle) > 0 {
+		var roots = x509.NewCertPool()
+		var intermediates = x509.NewCertPool()
+		if len(options.CABundle) > 0 {
+			for _, cert := range options.CABundle {
+				roots.AddCert(cert)
+			}
+			for _, cert := range append(append([]*x509.Certificate{}, certs...), cas...) {
+				intermediates.AddCert(cert)
+			}
+		} else {
+			if err = validateCertificateChain(chain, unused); err != nil {
+				return validationErrorf(ValidationReasonChainInvalid, "%s", err.Error())
+			}
+			if chain.Root == nil {
+				return validationErrorf(ValidationReasonChainInvalid, "root certificate of %s not found in tls.crt or ca.crt from secret %s", certificateName(leaf), secretname)
+			}
+			roots.AddCert(chain.Root)
+			for _, cert := range chain.Intermediates {
+				intermediates.AddCert(cert)
+			}
+		}
+		_, err = leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   now,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			return validationErrorf(ValidationReasonChainInvalid, "cannot verify certificate chain of %s: %s", certificateName(leaf), err.Error())
+		}
+	}
+	return nil
+}
+
+func signatureAlgorithmAllowed(algorithm x509.SignatureAlgorithm, allowed []string) bool {
+	for _, a := range allowed {
+		if strings.EqualFold(a, algorithm.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateCertificateBundle checks certificates without private key, such as
+// CA bundles, held in tls.crt of the Secret. They must be valid PEM, with at
+// least one certificate. Validity periods are not checked, since bundles
+// commonly carry certificates about to expire along with their replacements.
+func ValidateCertificateBundle(secret *apicorev1.Secret) error {
+	var secretname = fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)
+	tlsCrt, ok := secret.Data["tls.crt"]
+	if !ok || len(tlsCrt) == 0 {
+		return validationErrorf(ValidationReasonMissingData, "%s does not have certificates", secretname)
+	}
+	if _, err := ParseCertificates(tlsCrt); err != nil {
+		return validationErrorf(ValidationReasonInvalidCertificate, "cannot parse certificates from %s: %s", secretname, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	apicorev1 "k8s.io/api/core/v1"
+	apimachineryv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validationTestNow is the time certificates are validated at.
+var validationTestNow = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type validationTestCertificate struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// newValidationTestCertificate creates an ECDSA P-256 certificate valid for a
+// day around validationTestNow, signed by parent, or self-signed without it.
+func newValidationTestCertificate(t *testing.T, commonName string, ca bool, parent *validationTestCertificate) *validationTestCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             validationTestNow.Add(-12 * time.Hour),
+		NotAfter:              validationTestNow.Add(12 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	if ca {
+		template.IsCA = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	var signer = &validationTestCertificate{cert: template, key: key}
+	if parent != nil {
+		signer = parent
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer.cert, key.Public(), signer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &validationTestCertificate{cert: cert, key: key}
+}
+
+func (c *validationTestCertificate) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func validationTestPEM(certs ...*validationTestCertificate) []byte {
+	var data []byte
+	for _, c := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})...)
+	}
+	return data
+}
+
+func TestValidateCertificate(t *testing.T) {
+	var root = newValidationTestCertificate(t, "root", true, nil)
+	var intermediate = newValidationTestCertificate(t, "intermediate", true, root)
+	var leaf = newValidationTestCertificate(t, "leaf", false, intermediate)
+	var otherRoot = newValidationTestCertificate(t, "other root", true, nil)
+
+	var tests = []struct {
+		name    string
+		data    map[string][]byte
+		options ValidationOptions
+		reason  string
+	}{
+		{
+			name:   "MissingData",
+			data:   map[string][]byte{"tls.crt": validationTestPEM(leaf)},
+			reason: ValidationReasonMissingData,
+		},
+		{
+			name:   "InvalidPrivateKey",
+			data:   map[string][]byte{"tls.key": []byte("not a key"), "tls.crt": validationTestPEM(leaf)},
+			reason: ValidationReasonInvalidPrivateKey,
+		},
+		{
+			name:   "KeyMismatch",
+			data:   map[string][]byte{"tls.key": otherRoot.keyPEM(t), "tls.crt": validationTestPEM(leaf, intermediate)},
+			reason: ValidationReasonKeyMismatch,
+		},
+		{
+			name:    "NotYetValid",
+			data:    map[string][]byte{"tls.key": leaf.keyPEM(t), "tls.crt": validationTestPEM(leaf)},
+			options: ValidationOptions{Now: leaf.cert.NotBefore.Add(-time.Second)},
+			reason:  ValidationReasonNotYetValid,
+		},
+		{
+			name:    "Expired",
+			data:    map[string][]byte{"tls.key": leaf.keyPEM(t), "tls.crt": validationTestPEM(leaf)},
+			options: ValidationOptions{Now: leaf.cert.NotAfter.Add(time.Second)},
+			reason:  ValidationReasonExpired,
+		},
+		{
+			name:    "WeakKey",
+			data:    map[string][]byte{"tls.key": leaf.keyPEM(t), "tls.crt": validationTestPEM(leaf)},
+			options: ValidationOptions{MinECKeySize: 384, MinRSAKeySize: 2048},
+			reason:  ValidationReasonWeakKey,
+		},
+		{
+			name:    "SignatureAlgorithmNotAllowed",
+			data:    map[string][]byte{"tls.key": leaf.keyPEM(t), "tls.crt": validationTestPEM(leaf, intermediate)},
+			options: ValidationOptions{SignatureAlgorithms: []string{"SHA256-RSA", "ECDSA-SHA384"}},
+			reason:  ValidationReasonSignatureAlgorithm,
+		},
+		{
+			name:    "SignatureAlgorithmAllowed",
+			data:    map[string][]byte{"tls.key": leaf.keyPEM(t), "tls.crt": validationTestPEM(leaf, intermediate)},
+			options: ValidationOptions{SignatureAlgorithms: []string{"ecdsa-sha256"}},
+		},
+		{
+			name:    "ChainInvalid without root",
+			data:    map[string][]byte{"tls.key": leaf.keyPEM(t), "tls.crt": validationTestPEM(leaf, intermediate)},
+			options: ValidationOptions{VerifyChain: true},
+			reason:  ValidationReasonChainInvalid,
+		},
+		{
+			name:    "ChainInvalid with CABundle",
+			data:    map[string][]byte{"tls.key": leaf.keyPEM(t), "tls.crt": validationTestPEM(leaf, intermediate)},
+			options: ValidationOptions{CABundle: []*x509.Certificate{otherRoot.cert}},
+			reason:  ValidationReasonChainInvalid,
+		},
+		{
+			name:    "ChainInvalid with an unrelated ca.crt",
+			data:    map[string][]byte{"tls.key": leaf.keyPEM(t), "tls.crt": validationTestPEM(leaf, intermediate), "ca.crt": validationTestPEM(otherRoot)},
+			options: ValidationOptions{VerifyChain: true},
+			reason:  ValidationReasonChainInvalid,
+		},
+		{
+			name: "Valid without chain verification",
+			data: map[string][]byte{"tls.key": leaf.keyPEM(t), "tls.crt": validationTestPEM(leaf)},
+		},
+		{
+			name:    "Valid chain with ca.crt",
+			data:    map[string][]byte{"tls.key": leaf.keyPEM(t), "tls.crt": validationTestPEM(leaf, intermediate), "ca.crt": validationTestPEM(root)},
+			options: ValidationOptions{VerifyChain: true, MinECKeySize: 256, SignatureAlgorithms: []string{"ECDSA-SHA256"}},
+		},
+		{
+			name:    "Valid chain with CABundle",
+			data:    map[string][]byte{"tls.key": leaf.keyPEM(t), "tls.crt": validationTestPEM(leaf, intermediate)},
+			options: ValidationOptions{CABundle: []*x509.Certificate{otherRoot.cert, root.cert}},
+		},
+	}
+	for _, test := range tests {
+		var secret = &apicorev1.Secret{
+			ObjectMeta: apimachineryv1.ObjectMeta{Namespace: "default", Name: "example-tls"},
+			Data:       test.data,
+		}
+		if test.options.Now.IsZero() {
+			test.options.Now = validationTestNow
+		}
+		err := ValidateCertificate(secret, test.options)
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", test.name, err)
+			}
+			continue
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: expected a ValidationError, got %v", test.name, err)
+			continue
+		}
+		if validationErr.Reason != test.reason {
+			t.Errorf("%s: expected reason %s, got %s (%s)", test.name, test.reason, validationErr.Reason, validationErr.Message)
+		}
+	}
+}
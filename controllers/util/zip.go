@@ -0,0 +1,279 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Zip encryption methods, used when the archive is protected with a password.
+//
+//   - zipcrypto: traditional PKWARE encryption. Weak, but supported by every
+//     zip tool, including the ones built into Windows and macOS.
+//   - aes256: WinZip AES encryption (AE-2) with a 256-bit key. Supported by
+//     7-Zip, WinZip, WinRAR, macOS Archive Utility and most modern tools.
+const (
+	ZipEncryptionZipCrypto = "zipcrypto"
+	ZipEncryptionAES256    = "aes256"
+)
+
+// Zip compression methods.
+const (
+	ZipCompressionDeflate = "deflate"
+	ZipCompressionStore   = "store"
+)
+
+const (
+	zipMethodWinZipAES    = 99
+	zipExtraWinZipAES     = 0x9901
+	zipExtraTimestamp     = 0x5455
+	zipFlagEncrypted      = 0x1
+	zipVersion20          = 20
+	zipVersionWinZipAES   = 51
+	zipWinZipAESSaltSize  = 16
+	zipWinZipAESKeySize   = 32
+	zipWinZipAESAuthSize  = 10
+	zipWinZipAESIteration = 1000
+)
+
+// ZipOptions configures how zip files are created.
+type ZipOptions struct {
+	// Password protecting the archive entries. If empty, entries are not
+	// encrypted.
+	Password string
+
+	// Encryption is either ZipEncryptionZipCrypto (default) or
+	// ZipEncryptionAES256. Only used with a password.
+	Encryption string
+
+	// Compression is either ZipCompressionDeflate (default) or
+	// ZipCompressionStore.
+	Compression string
+
+	// CompressionLevel for deflate, from 1 (fastest) to 9 (best). Zero means
+	// the default level.
+	CompressionLevel int
+}
+
+// ZipFiles creates the zip file zipfilename in workspacedir with the given
+// files, also relative to workspacedir.
+func ZipFiles(workspacedir string, zipfilename string, files []string, options ZipOptions) error {
+	var err error
+	var method uint16
+	switch options.Compression {
+	case "", ZipCompressionDeflate:
+		method = zip.Deflate
+	case ZipCompressionStore:
+		method = zip.Store
+	default:
+		return fmt.Errorf("invalid zip compression %s", options.Compression)
+	}
+	switch options.Encryption {
+	case "", ZipEncryptionZipCrypto, ZipEncryptionAES256:
+	default:
+		return fmt.Errorf("invalid zip encryption %s", options.Encryption)
+	}
+	level := options.CompressionLevel
+	if level == 0 {
+		level = flate.DefaultCompression
+	} else if level < flate.BestSpeed || level > flate.BestCompression {
+		return fmt.Errorf("invalid zip compression level %d", level)
+	}
+
+	var archive bytes.Buffer
+	zipWriter := zip.NewWriter(&archive)
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(workspacedir, f))
+		if err != nil {
+			return err
+		}
+		if err = zipWriteEntry(zipWriter, f, data, method, level, options); err != nil {
+			return fmt.Errorf("cannot add %s to %s: %s", f, zipfilename, err.Error())
+		}
+	}
+	if err = zipWriter.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(workspacedir, zipfilename), archive.Bytes(), 0600)
+}
+
+// zipWriteEntry compresses and, with a password, encrypts one entry, writing
+// it raw into the archive.
+func zipWriteEntry(zipWriter *zip.Writer, name string, data []byte, method uint16, level int, options ZipOptions) error {
+	compressed := data
+	if method == zip.Deflate {
+		var buffer bytes.Buffer
+		flateWriter, err := flate.NewWriter(&buffer, level)
+		if err != nil {
+			return err
+		}
+		if _, err = flateWriter.Write(data); err != nil {
+			return err
+		}
+		if err = flateWriter.Close(); err != nil {
+			return err
+		}
+		compressed = buffer.Bytes()
+	}
+
+	header := &zip.FileHeader{
+		Name:               name,
+		Method:             method,
+		CRC32:              crc32.ChecksumIEEE(data),
+		UncompressedSize64: uint64(len(data)),
+	}
+	header.SetMode(0600)
+	// CreateRaw leaves the versions to the caller too.
+	header.CreatorVersion = header.CreatorVersion&0xff00 | zipVersion20
+	header.ReaderVersion = zipVersion20
+	zipSetModified(header, time.Now())
+
+	var err error
+	if options.Password != "" {
+		header.Flags |= zipFlagEncrypted
+		if options.Encryption == ZipEncryptionAES256 {
+			compressed, err = zipWinZipAESEncrypt(compressed, options.Password)
+			if err != nil {
+				return err
+			}
+			// AE-2 entries carry no CRC, as it would reveal information about the
+			// plain text. The actual compression method goes in the extra field.
+			header.Extra = append(header.Extra, zipWinZipAESExtra(method)...)
+			header.Method = zipMethodWinZipAES
+			header.ReaderVersion = zipVersionWinZipAES
+			header.CRC32 = 0
+		} else {
+			compressed, err = zipCryptoEncrypt(compressed, options.Password, header.CRC32)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	header.CompressedSize64 = uint64(len(compressed))
+
+	w, err := zipWriter.CreateRaw(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(compressed)
+	return err
+}
+
+// zipSetModified sets the modification time of an entry. CreateRaw writes the
+// header as is, so the MS-DOS date and time and the extended timestamp extra
+// field are filled here, as CreateHeader would do.
+func zipSetModified(header *zip.FileHeader, modified time.Time) {
+	header.Modified = modified
+	header.ModifiedDate = uint16(modified.Day() + int(modified.Month())<<5 + (modified.Year()-1980)<<9)
+	header.ModifiedTime = uint16(modified.Second()/2 + modified.Minute()<<5 + modified.Hour()<<11)
+
+	extra := make([]byte, 9)
+	binary.LittleEndian.PutUint16(extra[0:], zipExtraTimestamp)
+	binary.LittleEndian.PutUint16(extra[2:], 5)
+	extra[4] = 1 // modification time only
+	binary.LittleEndian.PutUint32(extra[5:], uint32(modified.Unix()))
+	header.Extra = append(header.Extra, extra...)
+}
+
+// zipCryptoEncrypt encrypts with the traditional PKWARE algorithm (APPNOTE
+// 6.1), prepending the 12 bytes encryption header.
+func zipCryptoEncrypt(data []byte, password string, crc uint32) ([]byte, error) {
+	keys := [3]uint32{0x12345678, 0x23456789, 0x34567890}
+	update := func(b byte) {
+		keys[0] = crc32.IEEETable[byte(keys[0])^b] ^ (keys[0] >> 8)
+		keys[1] = (keys[1]+(keys[0]&0xff))*134775813 + 1
+		keys[2] = crc32.IEEETable[byte(keys[2])^byte(keys[1]>>24)] ^ (keys[2] >> 8)
+	}
+	for _, b := range []byte(password) {
+		update(b)
+	}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(saltReader, header[:11]); err != nil {
+		return nil, err
+	}
+	// The last header byte lets readers check the password.
+	header[11] = byte(crc >> 24)
+
+	encrypted := make([]byte, 0, len(header)+len(data))
+	for _, b := range append(header, data...) {
+		temp := uint16(keys[2]) | 2
+		encrypted = append(encrypted, b^byte((uint32(temp)*uint32(temp^1))>>8))
+		update(b)
+	}
+	return encrypted, nil
+}
+
+// zipWinZipAESEncrypt encrypts with WinZip AES (AE-2), returning salt,
+// password verification value, cipher text and authentication code.
+func zipWinZipAESEncrypt(data []byte, password string) ([]byte, error) {
+	salt := make([]byte, zipWinZipAESSaltSize)
+	if _, err := io.ReadFull(saltReader, salt); err != nil {
+		return nil, err
+	}
+	keys := pbkdf2.Key([]byte(password), salt, zipWinZipAESIteration, 2*zipWinZipAESKeySize+2, sha1.New)
+	encryptionKey := keys[:zipWinZipAESKeySize]
+	authenticationKey := keys[zipWinZipAESKeySize : 2*zipWinZipAESKeySize]
+	verifier := keys[2*zipWinZipAESKeySize:]
+
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := make([]byte, len(data))
+	zipWinZipAESCTR(block, encrypted, data)
+
+	mac := hmac.New(sha1.New, authenticationKey)
+	mac.Write(encrypted)
+
+	result := make([]byte, 0, len(salt)+len(verifier)+len(encrypted)+zipWinZipAESAuthSize)
+	result = append(result, salt...)
+	result = append(result, verifier...)
+	result = append(result, encrypted...)
+	result = append(result, mac.Sum(nil)[:zipWinZipAESAuthSize]...)
+	return result, nil
+}
+
+// zipWinZipAESCTR is AES in counter mode as used by WinZip: a little endian
+// counter starting at 1, instead of the usual big endian one.
+func zipWinZipAESCTR(block cipher.Block, dst, src []byte) {
+	var counter, keystream [aes.BlockSize]byte
+	var n uint64
+	for i := 0; i < len(src); i += aes.BlockSize {
+		n++
+		binary.LittleEndian.PutUint64(counter[:8], n)
+		block.Encrypt(keystream[:], counter[:])
+		end := i + aes.BlockSize
+		if end > len(src) {
+			end = len(src)
+		}
+		for j := i; j < end; j++ {
+			dst[j] = src[j] ^ keystream[j-i]
+		}
+	}
+}
+
+// zipWinZipAESExtra is the AES extra data field of an AE-2 entry.
+func zipWinZipAESExtra(method uint16) []byte {
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], zipExtraWinZipAES)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], 2) // AE-2
+	copy(extra[6:], "AE")
+	extra[8] = 3 // 256-bit key
+	binary.LittleEndian.PutUint16(extra[9:], method)
+	return extra
+}
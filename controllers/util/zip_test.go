@@ -0,0 +1,239 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// zipTestEntry returns the only entry of an archive in testdata, and its raw
+// data.
+func zipTestEntry(t *testing.T, name string) (*zip.File, []byte) {
+	reader, err := zip.OpenReader(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reader.Close() })
+	if len(reader.File) != 1 {
+		t.Fatalf("expected 1 entry in %s, got %d", name, len(reader.File))
+	}
+	return reader.File[0], zipRawEntry(t, reader.File[0])
+}
+
+func zipRawEntry(t *testing.T, f *zip.File) []byte {
+	r, err := f.OpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// zipCryptoHeader finds the random bytes of the encryption header of a
+// ZipCrypto entry. Each encrypted byte only depends on the ones before it, so
+// they are found one at a time, as the byte whose encryption matches the
+// entry. Must be called after withFixedSalts.
+func zipCryptoHeader(t *testing.T, entry []byte, password string, crc uint32) []byte {
+	var header = make([]byte, 11)
+	for i := range header {
+		var found bool
+		for b := 0; b < 256 && !found; b++ {
+			header[i] = byte(b)
+			saltReader = bytes.NewReader(header)
+			encrypted, err := zipCryptoEncrypt(nil, password, crc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			found = encrypted[i] == entry[i]
+		}
+		if !found {
+			t.Fatalf("no encryption header byte %d matches the entry", i)
+		}
+	}
+	return header
+}
+
+// The entries of the archives in testdata were created by libarchive with:
+//
+//	bsdtar --format zip --options zip:encryption=<zipcrypt|aes256>,zip:compression=store \
+//	  --passphrase secret -cf <archive> cert-watch.txt
+var zipTestData = []byte("cert-watch certificate watcher\n")
+
+func TestZipCryptoEncrypt(t *testing.T) {
+	withFixedSalts(t)
+	f, entry := zipTestEntry(t, "zipcrypto.zip")
+	if f.Flags&zipFlagEncrypted == 0 || f.Method != zip.Store || f.CRC32 != crc32.ChecksumIEEE(zipTestData) {
+		t.Fatalf("unexpected entry %+v", f.FileHeader)
+	}
+	// libarchive writes a data descriptor, so the password check byte is the
+	// high byte of the modification time instead of the CRC (APPNOTE 6.1.6).
+	// It is passed in place of the CRC.
+	var check = uint32(f.ModifiedTime>>8) << 24
+
+	saltReader = bytes.NewReader(zipCryptoHeader(t, entry, "secret", check))
+	encrypted, err := zipCryptoEncrypt(zipTestData, "secret", check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encrypted, entry) {
+		t.Errorf("expected %x, got %x", entry, encrypted)
+	}
+}
+
+func TestZipWinZipAESEncrypt(t *testing.T) {
+	withFixedSalts(t)
+	f, entry := zipTestEntry(t, "aes256.zip")
+	if f.Flags&zipFlagEncrypted == 0 || f.Method != zipMethodWinZipAES {
+		t.Fatalf("unexpected entry %+v", f.FileHeader)
+	}
+	// The salt is stored in the clear at the start of the entry.
+	saltReader = bytes.NewReader(entry[:zipWinZipAESSaltSize])
+	encrypted, err := zipWinZipAESEncrypt(zipTestData, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encrypted, entry) {
+		t.Errorf("expected %x, got %x", entry, encrypted)
+	}
+
+	// A different password changes the verification value and the rest.
+	saltReader = bytes.NewReader(entry[:zipWinZipAESSaltSize])
+	encrypted, err = zipWinZipAESEncrypt(zipTestData, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(encrypted[zipWinZipAESSaltSize:zipWinZipAESSaltSize+2], entry[zipWinZipAESSaltSize:zipWinZipAESSaltSize+2]) {
+		t.Error("expected a different password verification value")
+	}
+}
+
+func TestZipFiles(t *testing.T) {
+	withFixedSalts(t)
+	var dir = t.TempDir()
+	var files = map[string][]byte{
+		"tls.crt": []byte(testCertificatePEM),
+		"tls.key": []byte(testKeyPEM),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []ZipOptions{
+		{},
+		{Compression: ZipCompressionStore},
+		{Password: "secret"},
+		{Password: "secret", Compression: ZipCompressionStore, Encryption: ZipEncryptionZipCrypto},
+		{Password: "secret", Encryption: ZipEncryptionAES256, CompressionLevel: 9},
+		{Password: "secret", Compression: ZipCompressionStore, Encryption: ZipEncryptionAES256},
+	}
+	var start = time.Now().Truncate(time.Second)
+	for _, options := range tests {
+		// zipCheckEntry replaces saltReader as well.
+		saltReader = &sequenceReader{}
+		if err := ZipFiles(dir, "certs.zip", []string{"tls.crt", "tls.key"}, options); err != nil {
+			t.Fatal(err)
+		}
+		reader, err := zip.OpenReader(filepath.Join(dir, "certs.zip"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reader.File) != 2 {
+			t.Errorf("%+v: expected 2 entries, got %d", options, len(reader.File))
+		}
+		for _, f := range reader.File {
+			if f.Modified.Before(start) || f.Modified.After(time.Now()) || f.ModifiedDate == 0 {
+				t.Errorf("%+v: expected %s to be modified now, got %s (MS-DOS date %x)", options, f.Name, f.Modified, f.ModifiedDate)
+			}
+			zipCheckEntry(t, f, options, files[f.Name])
+		}
+		reader.Close()
+	}
+
+	for _, options := range []ZipOptions{{Compression: "bzip2"}, {Encryption: "des"}, {CompressionLevel: 10}} {
+		if err := ZipFiles(dir, "certs.zip", []string{"tls.crt"}, options); err == nil {
+			t.Errorf("%+v: expected an error", options)
+		}
+	}
+}
+
+// zipCheckEntry checks an archive entry against the file contents. The
+// encryption of the entries is checked by encrypting the same data with the
+// salts found in the entry, as the encryption itself is checked against the
+// entries in testdata.
+func zipCheckEntry(t *testing.T, f *zip.File, options ZipOptions, data []byte) {
+	if options.Password == "" {
+		if f.Flags&zipFlagEncrypted != 0 {
+			t.Errorf("%+v: expected %s not to be encrypted", options, f.Name)
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, data) {
+			t.Errorf("%+v: unexpected content of %s", options, f.Name)
+		}
+		return
+	}
+
+	if f.Flags&zipFlagEncrypted == 0 {
+		t.Errorf("%+v: expected %s to be encrypted", options, f.Name)
+	}
+	var method = zip.Deflate
+	var compressed = data
+	if options.Compression == ZipCompressionStore {
+		method = zip.Store
+	} else {
+		var buffer bytes.Buffer
+		level := options.CompressionLevel
+		if level == 0 {
+			level = flate.DefaultCompression
+		}
+		w, _ := flate.NewWriter(&buffer, level)
+		_, _ = w.Write(data)
+		w.Close()
+		compressed = buffer.Bytes()
+	}
+
+	var raw = zipRawEntry(t, f)
+	var encrypted []byte
+	var err error
+	if options.Encryption == ZipEncryptionAES256 {
+		extra := f.Extra[len(f.Extra)-11:]
+		if f.Method != zipMethodWinZipAES || f.ReaderVersion != zipVersionWinZipAES || f.CRC32 != 0 || !bytes.Equal(extra[:2], []byte{0x01, 0x99}) {
+			t.Fatalf("%+v: expected an AE-2 entry for %s", options, f.Name)
+		}
+		if extra[9] != byte(method) {
+			t.Errorf("%+v: expected method %d for %s, got %d", options, method, f.Name, extra[9])
+		}
+		saltReader = bytes.NewReader(raw[:zipWinZipAESSaltSize])
+		encrypted, err = zipWinZipAESEncrypt(compressed, options.Password)
+	} else {
+		if f.Method != method || f.CRC32 != crc32.ChecksumIEEE(data) {
+			t.Errorf("%+v: expected method %d and the CRC of %s", options, method, f.Name)
+		}
+		saltReader = bytes.NewReader(zipCryptoHeader(t, raw, options.Password, f.CRC32))
+		encrypted, err = zipCryptoEncrypt(compressed, options.Password, f.CRC32)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encrypted, raw) {
+		t.Errorf("%+v: unexpected encrypted content of %s", options, f.Name)
+	}
+}
@@ -15,8 +15,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	certwatchv1 "github.com/jhmorimoto/cert-watch/apis/certwatch/v1"
+	certmanagercontrollers "github.com/jhmorimoto/cert-watch/controllers/certmanager"
 	certwatchcontrollers "github.com/jhmorimoto/cert-watch/controllers/certwatch"
 	corecontrollers "github.com/jhmorimoto/cert-watch/controllers/core"
+	"github.com/jhmorimoto/cert-watch/controllers/util"
 	"github.com/magiconair/properties"
 	//+kubebuilder:scaffold:imports
 )
@@ -39,8 +41,10 @@ func main() {
 	var probeAddr string
 	var logDevMode bool
 	var emailConfigFile string
+	var maxConcurrentReconciles int
 
 	flag.StringVar(&emailConfigFile, "emailconfig", "", "Path properties file that holds email configuration.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 8, "Maximum number of CertWatchers processed at the same time.")
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&logDevMode, "log-dev-mode", true, "Enable/disable log dev mode (zap). If disabled, logs output defaults to json.")
@@ -76,6 +80,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	mailSender := util.NewMailSender()
+	if err = mgr.Add(mailSender); err != nil {
+		setupLog.Error(err, "unable to set up mail sender")
+		os.Exit(1)
+	}
+
 	if err = (&corecontrollers.SecretReconciler{
 		Client:        mgr.GetClient(),
 		Scheme:        mgr.GetScheme(),
@@ -84,15 +94,41 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Secret")
 		os.Exit(1)
 	}
+	if err = (&corecontrollers.ConfigMapReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor("ConfigMapReconciler"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ConfigMap")
+		os.Exit(1)
+	}
+	if err = (&certmanagercontrollers.CertificateReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor("CertificateReconciler"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Certificate")
+		os.Exit(1)
+	}
 	if err = (&certwatchcontrollers.CertWatcherReconciler{
 		Client:             mgr.GetClient(),
 		Scheme:             mgr.GetScheme(),
 		EmailConfiguration: emailConfiguration,
 		EventRecorder:      mgr.GetEventRecorderFor("CertWatcherReconciler"),
+		MailSender:         mailSender,
+		MaxConcurrent:      maxConcurrentReconciles,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CertWatcher")
 		os.Exit(1)
 	}
+	if err = (&certwatchcontrollers.ClusterCertWatcherReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor("ClusterCertWatcherReconciler"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterCertWatcher")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {